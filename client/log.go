@@ -0,0 +1,79 @@
+package client
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"encoding/hex"
+	"errors"
+)
+
+// VerifyTreeHead checks that the tree head (GET /log/sth) is signed by the operator. A monitor keeps the
+// verified tree heads: two heads of the same size with different roots, or two heads which are not
+// consistent (see VerifyConsistencyProof), prove that the operator rewrote the log.
+func VerifyTreeHead(head *model.TreeHead, operatorPubKey string) error {
+	if _, err := hex.DecodeString(head.RootHash); err != nil || len(head.RootHash) != 64 {
+		return errors.New("Root hash must be 32 hex encoded bytes")
+	}
+
+	if head.PubKey != operatorPubKey {
+		return errors.New("Tree head is not signed by the operator")
+	}
+
+	pubKey, err := ecdsa.ParsePubKey(operatorPubKey)
+	if err != nil {
+		return err
+	}
+
+	if verified, _ := ecdsa.Verify(pubKey, head.Hash(), head.Signature); !verified {
+		return errors.New("Signature of the tree head is not valid")
+	}
+
+	return nil
+}
+
+// VerifyConsistencyProof checks that the proof (GET /log/consistency) shows the log of the first tree head
+// is a prefix of the log of the second. The tree heads must be verified with VerifyTreeHead.
+func VerifyConsistencyProof(proof *model.ConsistencyProof, first *model.TreeHead, second *model.TreeHead) error {
+	if proof.From != first.TreeSize || proof.To != second.TreeSize {
+		return errors.New("Proof is for other tree sizes")
+	}
+
+	hashes, err := decodeHashes(proof.Proof)
+	if err != nil {
+		return err
+	}
+
+	firstRoot, _ := hex.DecodeString(first.RootHash)
+	secondRoot, _ := hex.DecodeString(second.RootHash)
+
+	return model.VerifyConsistency(int(proof.From), int(proof.To), firstRoot, secondRoot, hashes)
+}
+
+// VerifyInclusionProof checks that the proof (GET /log/inclusion) shows the transaction is in the log of
+// the tree head. The tree head must be verified with VerifyTreeHead.
+func VerifyInclusionProof(proof *model.InclusionProof, head *model.TreeHead) error {
+	if proof.TreeSize != head.TreeSize {
+		return errors.New("Proof is for another tree size")
+	}
+
+	path, err := decodeHashes(proof.Path)
+	if err != nil {
+		return err
+	}
+
+	root, _ := hex.DecodeString(head.RootHash)
+
+	return model.VerifyMerklePath([]byte(proof.TxID), int(proof.LeafIndex), int(proof.TreeSize), path, root)
+}
+
+// decodeHashes decodes the hex encoded hashes of a proof.
+func decodeHashes(hashes []string) ([][]byte, error) {
+	results := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		var err error
+		if results[i], err = hex.DecodeString(hash); err != nil {
+			return nil, errors.New("Proof hashes must be hex encoded")
+		}
+	}
+	return results, nil
+}
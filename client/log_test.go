@@ -0,0 +1,124 @@
+package client
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newLog returns the tree of n TxIDs and a function returning the tree head of a size signed by a new
+// operator key, and the public key of the operator.
+func newLog(n int) (*model.MerkleTree, func(size int) *model.TreeHead, string) {
+	tree := model.NewMerkleTree(nil)
+	for i := 0; i < n; i++ {
+		tree.Append([]byte(strconv.Itoa(i)))
+	}
+
+	key, _ := ecdsa.GenerateNewKey()
+	pubKey := ecdsa.ExportPubKey(&key.PublicKey)
+
+	head := func(size int) *model.TreeHead {
+		root, _ := tree.Root(size)
+		head := &model.TreeHead{TreeSize: int64(size), Timestamp: time.Now().UTC().Truncate(time.Millisecond), RootHash: hex.EncodeToString(root), PubKey: pubKey}
+		head.Signature, _ = ecdsa.Sign(key, head.Hash())
+		return head
+	}
+
+	return tree, head, pubKey
+}
+
+func TestVerifyTreeHead(t *testing.T) {
+	_, head, pubKey := newLog(5)
+	other, _ := ecdsa.GenerateNewKey()
+
+	if err := VerifyTreeHead(head(5), pubKey); err != nil {
+		t.Error("Tree head not verified:", err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		mutate func(head *model.TreeHead, pubKey *string)
+	}{
+		{"another size", func(head *model.TreeHead, pubKey *string) { head.TreeSize = 4 }},
+		{"another time", func(head *model.TreeHead, pubKey *string) { head.Timestamp = head.Timestamp.Add(time.Millisecond) }},
+		{"another root", func(head *model.TreeHead, pubKey *string) { head.RootHash = model.ZeroHash }},
+		{"root not hex", func(head *model.TreeHead, pubKey *string) { head.RootHash = "x" }},
+		{"another operator", func(head *model.TreeHead, pubKey *string) { *pubKey = ecdsa.ExportPubKey(&other.PublicKey) }},
+		{"no signature", func(head *model.TreeHead, pubKey *string) { head.Signature = "" }},
+	} {
+		h, key := head(5), pubKey
+		c.mutate(h, &key)
+
+		if err := VerifyTreeHead(h, key); err == nil {
+			t.Error("Invalid tree head verified:", c.name)
+		}
+	}
+}
+
+func TestVerifyConsistencyProof(t *testing.T) {
+	tree, head, _ := newLog(7)
+
+	for from := 0; from <= 7; from++ {
+		for to := from; to <= 7; to++ {
+			hashes, _ := tree.ConsistencyProof(from, to)
+			proof := &model.ConsistencyProof{From: int64(from), To: int64(to)}
+			for _, hash := range hashes {
+				proof.Proof = append(proof.Proof, hex.EncodeToString(hash))
+			}
+
+			if err := VerifyConsistencyProof(proof, head(from), head(to)); err != nil {
+				t.Error("Consistency proof not verified:", from, to, err)
+			}
+		}
+	}
+
+	hashes, _ := tree.ConsistencyProof(3, 7)
+	proof := &model.ConsistencyProof{From: 3, To: 7}
+	for _, hash := range hashes {
+		proof.Proof = append(proof.Proof, hex.EncodeToString(hash))
+	}
+
+	// A log rewritten after the first tree head is not consistent with it
+	rewritten, rewrittenHead, _ := newLog(0)
+	for _, txID := range []string{"0", "1", "x", "3", "4", "5", "6"} {
+		rewritten.Append([]byte(txID))
+	}
+	if err := VerifyConsistencyProof(proof, head(3), rewrittenHead(7)); err == nil {
+		t.Error("Rewritten log verified")
+	}
+
+	if err := VerifyConsistencyProof(proof, head(4), head(7)); err == nil {
+		t.Error("Proof of other tree sizes verified")
+	}
+
+	proof.Proof[0] = "x"
+	if err := VerifyConsistencyProof(proof, head(3), head(7)); err == nil {
+		t.Error("Proof not hex verified")
+	}
+}
+
+func TestVerifyInclusionProof(t *testing.T) {
+	tree, head, _ := newLog(7)
+
+	path, _ := tree.InclusionProof(2, 7)
+	proof := &model.InclusionProof{TxID: "2", LeafIndex: 2, TreeSize: 7}
+	for _, hash := range path {
+		proof.Path = append(proof.Path, hex.EncodeToString(hash))
+	}
+
+	if err := VerifyInclusionProof(proof, head(7)); err != nil {
+		t.Error("Inclusion proof not verified:", err)
+	}
+
+	if err := VerifyInclusionProof(proof, head(6)); err == nil {
+		t.Error("Proof of another tree size verified")
+	}
+
+	proof.TxID = "3"
+	if err := VerifyInclusionProof(proof, head(7)); err == nil {
+		t.Error("Proof of another transaction verified")
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"encoding/hex"
+	"errors"
+)
+
+// VerifyTransactionProof checks offline that the proof (GET /transactions/{id}/proof) shows the transaction
+// with the TxID is in a block signed by the operator: the audit path leads from the TxID to the Merkle root
+// of the header, the block hash is the hash of the header and meets its proof of work target, and the
+// operator signed the block hash.
+// operatorPubKey is the public key of the operator, which the client knows from a trusted source.
+// minDifficulty is the lowest difficulty of the target (see model.Difficulty) the client accepts, a block
+// without proof of work is only accepted if it is 0.
+func VerifyTransactionProof(proof *model.TransactionProof, txID string, operatorPubKey string, minDifficulty float64) error {
+	if proof.TxID != txID {
+		return errors.New("Proof is for another transaction")
+	}
+
+	path := make([][]byte, len(proof.Path))
+	for i, hash := range proof.Path {
+		var err error
+		if path[i], err = hex.DecodeString(hash); err != nil {
+			return errors.New("Audit path must be hex encoded")
+		}
+	}
+
+	root, err := hex.DecodeString(proof.Header.MerkleRoot)
+	if err != nil {
+		return errors.New("Merkle root must be hex encoded")
+	}
+
+	if err := model.VerifyMerklePath([]byte(txID), proof.Index, proof.TreeSize, path, root); err != nil {
+		return err
+	}
+
+	if proof.Header.Hash() != proof.BlockHash {
+		return errors.New("Block hash does not match the header")
+	}
+
+	if proof.Header.Target != "" && !proof.Header.MeetsTarget() {
+		return errors.New("Block hash does not meet the proof of work target")
+	}
+
+	if minDifficulty > 0 && model.Difficulty(proof.Header.Target) < minDifficulty {
+		return errors.New("Proof of work target is easier than the minimum difficulty")
+	}
+
+	if proof.PubKey != operatorPubKey {
+		return errors.New("Proof is not signed by the operator")
+	}
+
+	pubKey, err := ecdsa.ParsePubKey(operatorPubKey)
+	if err != nil {
+		return err
+	}
+
+	hash, _ := hex.DecodeString(proof.BlockHash)
+	if verified, _ := ecdsa.Verify(pubKey, hash, proof.Signature); !verified {
+		return errors.New("Signature of the block is not valid")
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"encoding/hex"
+	"testing"
+)
+
+// newProof returns the proof of the TxID at index of a block signed by a new operator key, and the
+// public key of the operator.
+func newProof(txIDs []string, index int) (*model.TransactionProof, string) {
+	block := model.NewBlock(nil, txIDs)
+
+	leaves := make([][]byte, len(txIDs))
+	for i, txID := range txIDs {
+		leaves[i] = []byte(txID)
+	}
+
+	proof := &model.TransactionProof{TxID: txIDs[index], Index: index, TreeSize: len(txIDs), BlockHash: block.Hash, Header: block.Header}
+	for _, hash := range model.MerklePath(leaves, index) {
+		proof.Path = append(proof.Path, hex.EncodeToString(hash))
+	}
+
+	key, _ := ecdsa.GenerateNewKey()
+	hash, _ := hex.DecodeString(block.Hash)
+	proof.PubKey = ecdsa.ExportPubKey(&key.PublicKey)
+	proof.Signature, _ = ecdsa.Sign(key, hash)
+
+	return proof, proof.PubKey
+}
+
+func TestVerifyTransactionProof(t *testing.T) {
+	txIDs := []string{"a", "b", "c", "d", "e"}
+
+	for index, txID := range txIDs {
+		proof, pubKey := newProof(txIDs, index)
+		if err := VerifyTransactionProof(proof, txID, pubKey, 0); err != nil {
+			t.Error("Proof not verified:", index, err)
+		}
+	}
+
+	// The block of the proof has no proof of work
+	if proof, pubKey := newProof(txIDs, 1); VerifyTransactionProof(proof, "b", pubKey, 1) == nil {
+		t.Error("Proof without proof of work verified")
+	}
+
+	other, _ := ecdsa.GenerateNewKey()
+
+	for _, c := range []struct {
+		name   string
+		txID   string
+		mutate func(proof *model.TransactionProof, pubKey *string)
+	}{
+		{"another transaction", "c", func(proof *model.TransactionProof, pubKey *string) {}},
+		{"another index", "b", func(proof *model.TransactionProof, pubKey *string) { proof.Index = 2 }},
+		{"path not hex", "b", func(proof *model.TransactionProof, pubKey *string) { proof.Path[0] = "x" }},
+		{"another root", "b", func(proof *model.TransactionProof, pubKey *string) { proof.Header.MerkleRoot = model.ZeroHash }},
+		{"another header", "b", func(proof *model.TransactionProof, pubKey *string) { proof.Header.Height = 1 }},
+		{"target not met", "b", func(proof *model.TransactionProof, pubKey *string) {
+			proof.Header.Target = model.TargetFromBits(64)
+			proof.BlockHash = proof.Header.Hash()
+		}},
+		{"another operator", "b", func(proof *model.TransactionProof, pubKey *string) { *pubKey = ecdsa.ExportPubKey(&other.PublicKey) }},
+		{"signed by another key", "b", func(proof *model.TransactionProof, pubKey *string) {
+			*pubKey = ecdsa.ExportPubKey(&other.PublicKey)
+			proof.PubKey = *pubKey
+		}},
+		{"no signature", "b", func(proof *model.TransactionProof, pubKey *string) { proof.Signature = "" }},
+	} {
+		proof, pubKey := newProof(txIDs, 1)
+		c.mutate(proof, &pubKey)
+
+		if err := VerifyTransactionProof(proof, c.txID, pubKey, 0); err == nil {
+			t.Error("Invalid proof verified:", c.name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"errors"
+)
+
+// VerifyReceipt checks offline that the receipt (returned by POST /transactions) shows the operator accepted
+// the transaction with the TxID into the mempool: the operator signed the TxID, the acceptance time and the
+// sequence number. It does not show the transaction is confirmed (see GET /transactions/{id}/proof).
+// operatorPubKey is the public key of the operator, which the client knows from a trusted source.
+func VerifyReceipt(receipt *model.Receipt, txID string, operatorPubKey string) error {
+	if receipt.TxID != txID {
+		return errors.New("Receipt is for another transaction")
+	}
+
+	if receipt.PubKey != operatorPubKey {
+		return errors.New("Receipt is not signed by the operator")
+	}
+
+	pubKey, err := ecdsa.ParsePubKey(operatorPubKey)
+	if err != nil {
+		return err
+	}
+
+	if verified, _ := ecdsa.Verify(pubKey, receipt.Hash(), receipt.Signature); !verified {
+		return errors.New("Signature of the receipt is not valid")
+	}
+
+	return nil
+}
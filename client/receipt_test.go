@@ -0,0 +1,52 @@
+package client
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"testing"
+	"time"
+)
+
+// newReceipt returns the receipt of the TxID signed by a new operator key, and the public key of the
+// operator.
+func newReceipt(txID string) (*model.Receipt, string) {
+	key, _ := ecdsa.GenerateNewKey()
+
+	receipt := &model.Receipt{TxID: txID, AcceptedAt: time.Now().UTC(), Sequence: 7, PubKey: ecdsa.ExportPubKey(&key.PublicKey)}
+	receipt.Signature, _ = ecdsa.Sign(key, receipt.Hash())
+
+	return receipt, receipt.PubKey
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	receipt, pubKey := newReceipt("a")
+	if err := VerifyReceipt(receipt, "a", pubKey); err != nil {
+		t.Error("Receipt not verified:", err)
+	}
+
+	other, _ := ecdsa.GenerateNewKey()
+
+	for _, c := range []struct {
+		name   string
+		txID   string
+		mutate func(receipt *model.Receipt, pubKey *string)
+	}{
+		{"another transaction", "b", func(receipt *model.Receipt, pubKey *string) {}},
+		{"another TxID", "b", func(receipt *model.Receipt, pubKey *string) { receipt.TxID = "b" }},
+		{"another time", "a", func(receipt *model.Receipt, pubKey *string) { receipt.AcceptedAt = receipt.AcceptedAt.Add(-time.Hour) }},
+		{"another sequence", "a", func(receipt *model.Receipt, pubKey *string) { receipt.Sequence = 1 }},
+		{"another operator", "a", func(receipt *model.Receipt, pubKey *string) { *pubKey = ecdsa.ExportPubKey(&other.PublicKey) }},
+		{"signed by another key", "a", func(receipt *model.Receipt, pubKey *string) {
+			*pubKey = ecdsa.ExportPubKey(&other.PublicKey)
+			receipt.PubKey = *pubKey
+		}},
+		{"no signature", "a", func(receipt *model.Receipt, pubKey *string) { receipt.Signature = "" }},
+	} {
+		receipt, pubKey := newReceipt("a")
+		c.mutate(receipt, &pubKey)
+
+		if err := VerifyReceipt(receipt, c.txID, pubKey); err == nil {
+			t.Error("Invalid receipt verified:", c.name)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cryptocoin-server/service"
+	"flag"
+	"fmt"
+)
+
+// checkTimestamps prints the transactions whose signature does not verify with the stored timestamp.
+// It returns false if any transaction is affected.
+func checkTimestamps() (bool, error) {
+	affected, err := service.CheckTimestamps()
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range affected {
+		reason := "signature does not verify"
+		if t.TimestampInSeconds {
+			reason += ", timestamp was stored in seconds"
+		}
+
+		fmt.Println(t.TxID, t.Timestamp.Format("2006-01-02T15:04:05.999999999Z07:00"), reason)
+	}
+
+	fmt.Println(len(affected), "affected transactions")
+
+	return len(affected) == 0, nil
+}
+
+// verifyLedger prints the violations of the rules of the ledger found by service.VerifyLedger, with
+// -quarantine it quarantines the violating transactions. It returns false if there is any violation.
+func verifyLedger(args []string) (bool, error) {
+	flags := flag.NewFlagSet("verify-ledger", flag.ContinueOnError)
+	quarantine := flags.Bool("quarantine", false, "quarantine the transactions breaking a rule, so their outputs can not be spent")
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+
+	report, err := service.VerifyLedger(*quarantine)
+	if err != nil {
+		return false, err
+	}
+
+	for _, violation := range report.Violations {
+		txID := violation.TxID
+		if txID == "" {
+			txID = "ledger"
+		}
+		fmt.Println(txID, violation.Code, violation.Message)
+	}
+
+	fmt.Println(report.Transactions, "transactions")
+	fmt.Println("genesis allocation", report.GenesisAllocation, "supply", report.Supply)
+	fmt.Println(len(report.Violations), "violations")
+	if len(report.LostPrecision) > 0 {
+		fmt.Println(len(report.LostPrecision), "transactions with a timestamp stored in seconds, see check-timestamps")
+	}
+	if *quarantine {
+		fmt.Println(len(report.Quarantined), "quarantined transactions")
+	}
+
+	return len(report.Violations) == 0, nil
+}
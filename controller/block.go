@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"cryptocoin-server/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// InitBlockController initializes the controller. A hash is 64 hex characters, so it is matched before a height.
+func InitBlockController(router *mux.Router) {
+	router.HandleFunc("/blocks", GetBlocks).Methods("GET")
+	router.HandleFunc("/blocks/{hash:[0-9a-f]{64}}", GetBlockByHash).Methods("GET")
+	router.HandleFunc("/blocks/{height:[0-9]+}", GetBlock).Methods("GET")
+}
+
+// GetBlocks returns a page of blocks, latest first. Optional parameters select the page (order, limit, and
+// from, the height of the first block).
+func GetBlocks(w http.ResponseWriter, r *http.Request) {
+	from, err := int64Param(r, "from")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if from != nil && *from < 0 {
+		writeError(w, errors.New("Parameter from must not be negative"))
+		return
+	}
+
+	descending := true
+	switch r.FormValue("order") {
+	case "", "desc":
+	case "asc":
+		descending = false
+	default:
+		writeError(w, errors.New("Parameter order must be asc or desc"))
+		return
+	}
+
+	limit, err := limitParam(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Without from, the page starts at the last block, or at the first block in ascending order
+	height := int64(-1)
+	if from != nil {
+		height = *from
+	}
+
+	blocks, err := service.GetBlocks(height, limit, descending)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(blocks)
+}
+
+// GetBlock returns the block at a height.
+func GetBlock(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.ParseInt(mux.Vars(r)["height"], 10, 64)
+	if err != nil {
+		writeError(w, errors.New("Height is out of range"))
+		return
+	}
+
+	block, err := service.GetBlock(height)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(block)
+}
+
+// GetBlockByHash returns the block with a hash.
+func GetBlockByHash(w http.ResponseWriter, r *http.Request) {
+	block, err := service.GetBlockByHash(mux.Vars(r)["hash"])
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(block)
+}
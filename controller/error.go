@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"cryptocoin-server/repository"
+	"cryptocoin-server/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// errorResponse is the JSON body of an error. Index is the position of the rejected transaction in
+// a batch.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Index   *int   `json:"index,omitempty"`
+}
+
+// writeError writes the error as a JSON error object, with the status code of the type of the error.
+func writeError(w http.ResponseWriter, err error) {
+	response := errorResponse{Code: service.ErrorCode(err), Message: err.Error()}
+
+	var batchErr *service.BatchError
+	if errors.As(err, &batchErr) {
+		response.Message = batchErr.Err.Error()
+		response.Index = &batchErr.Index
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errorStatus(err))
+	json.NewEncoder(w).Encode(response)
+}
+
+// errorStatus returns the HTTP status code of the error.
+func errorStatus(err error) int {
+	var ruleErr *service.RuleError
+
+	switch {
+	case errors.As(err, &ruleErr), errors.Is(err, service.ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrBlockNotFound), errors.Is(err, service.ErrNotInBlock),
+		errors.Is(err, service.ErrNotInLog):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrAlreadySpent):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrStorageUnavailable), errors.Is(err, service.ErrMempoolFull):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusBadRequest
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"cryptocoin-server/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// InitFeeController initializes the controller.
+func InitFeeController(router *mux.Router) {
+	router.HandleFunc("/fees/estimate", EstimateFee).Methods("GET")
+}
+
+// EstimateFee returns the minimum fee of a transaction. The optional inputs and outputs parameters are the
+// number of inputs and outputs of the transaction (1 and 2 by default: a payment and the change).
+func EstimateFee(w http.ResponseWriter, r *http.Request) {
+	inputs, err := intParam(r, "inputs", 1)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	outputs, err := intParam(r, "outputs", 2)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	estimate, err := service.EstimateFee(inputs, outputs)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(estimate)
+}
@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"cryptocoin-server/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// InitHealthController initializes the controller.
+func InitHealthController(router *mux.Router) {
+	router.HandleFunc("/health", GetHealth).Methods("GET")
+}
+
+// GetHealth returns {"status": "ok"} if the storage is available, or the storage_unavailable error.
+func GetHealth(w http.ResponseWriter, r *http.Request) {
+	if err := service.CheckHealth(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"cryptocoin-server/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// InitLogController initializes the controller.
+func InitLogController(router *mux.Router) {
+	router.HandleFunc("/log/sth", GetTreeHead).Methods("GET")
+	router.HandleFunc("/log/consistency", GetConsistencyProof).Methods("GET")
+	router.HandleFunc("/log/inclusion", GetInclusionProof).Methods("GET")
+	router.HandleFunc("/log/entries", GetLogEntries).Methods("GET")
+}
+
+// GetTreeHead returns the signed tree head of the log, which client.VerifyTreeHead checks.
+func GetTreeHead(w http.ResponseWriter, r *http.Request) {
+	head, err := service.GetTreeHead()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(head)
+}
+
+// GetConsistencyProof returns the proof that the log of size from is a prefix of the log of size to, the
+// size of the log if it is not set. client.VerifyConsistencyProof checks it.
+func GetConsistencyProof(w http.ResponseWriter, r *http.Request) {
+	from, err := int64Param(r, "from")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if from == nil {
+		writeError(w, errors.New("Parameter from is required"))
+		return
+	}
+
+	to, err := sizeParam(r, "to")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	proof, err := service.GetConsistencyProof(*from, to)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(proof)
+}
+
+// GetInclusionProof returns the audit path of the transaction txId in the log of size size, the size of the
+// log if it is not set. client.VerifyInclusionProof checks it.
+func GetInclusionProof(w http.ResponseWriter, r *http.Request) {
+	txID := r.FormValue("txId")
+	if txID == "" {
+		writeError(w, errors.New("Parameter txId is required"))
+		return
+	}
+
+	size, err := sizeParam(r, "size")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	proof, err := service.GetInclusionProof(txID, size)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(proof)
+}
+
+// GetLogEntries returns the TxIDs of the log from index start to index end, excluded, at most 100.
+func GetLogEntries(w http.ResponseWriter, r *http.Request) {
+	start, err := intParam(r, "start", 0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	end, err := intParam(r, "end", start+100)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entries, err := service.GetLogEntries(int64(start), int64(end))
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(entries)
+}
+
+// sizeParam parses an optional tree size parameter, -1 (the size of the log) if it is not set.
+func sizeParam(r *http.Request, name string) (int64, error) {
+	size, err := int64Param(r, name)
+	if err != nil || size == nil {
+		return -1, err
+	}
+
+	if *size < 0 {
+		return 0, errors.New("Parameter " + name + " must not be negative")
+	}
+
+	return *size, nil
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"cryptocoin-server/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// InitMiningController initializes the controller.
+func InitMiningController(router *mux.Router) {
+	router.HandleFunc("/mining", GetMiningStatus).Methods("GET")
+}
+
+// GetMiningStatus returns whether blocks are sealed with proof of work, the hashrate of the miner and the
+// target of the next block.
+func GetMiningStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := service.GetMiningStatus()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
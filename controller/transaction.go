@@ -5,8 +5,11 @@ import (
 	"cryptocoin-server/repository"
 	"cryptocoin-server/service"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -15,69 +18,332 @@ import (
 func InitTransactionController(router *mux.Router) {
 	router.HandleFunc("/transactions", GetTransactions).Methods("GET")
 	router.HandleFunc("/transactions", CreateTransactions).Methods("POST")
+	router.HandleFunc("/transactions/validate", ValidateTransactions).Methods("POST")
 	router.HandleFunc("/transactions/genesis", CreateGenesisTransaction).Methods("POST")
 	router.HandleFunc("/transactions/transfer", TransferFromGenesisAccount).Methods("POST")
 	router.HandleFunc("/transactions/{id}", GetTransaction).Methods("GET")
+	router.HandleFunc("/transactions/{id}/status", GetTransactionStatus).Methods("GET")
+	router.HandleFunc("/transactions/{id}/proof", GetTransactionProof).Methods("GET")
+	router.HandleFunc("/transactions/{id}/history", GetTransactionHistory).Methods("GET")
+	router.HandleFunc("/transactions/{id}/descendants", GetTransactionDescendants).Methods("GET")
 }
 
-// GetTransactions returns all latest transactions (limit 25).
+// GetTransactions returns a page of transactions, latest first. Optional parameters filter the transactions
+// (toAddress and pubKey of an output and input, from, to, minValue and maxValue of the total output value,
+// spent for all outputs spent) and select the page (order, limit, cursor).
 func GetTransactions(w http.ResponseWriter, r *http.Request) {
-	transactions, err := repository.GetTransactions()
+	filter, err := parseFilter(r)
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(transactions)
+	transactions, next, err := service.GetTransactions(*filter)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeTransactionPage(w, transactions, next)
+}
+
+// parseFilter parses and validates the filter parameters of GetTransactions.
+func parseFilter(r *http.Request) (*repository.Filter, error) {
+	filter := new(repository.Filter)
+	var err error
+
+	filter.ToAddress = r.FormValue("toAddress")
+	filter.PubKey = r.FormValue("pubKey")
+
+	if filter.From, err = timeParam(r, "from"); err != nil {
+		return nil, err
+	}
+
+	if filter.To, err = timeParam(r, "to"); err != nil {
+		return nil, err
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, errors.New("Parameter from must be before to")
+	}
+
+	if filter.MinValue, err = int64Param(r, "minValue"); err != nil {
+		return nil, err
+	}
+
+	if filter.MaxValue, err = int64Param(r, "maxValue"); err != nil {
+		return nil, err
+	}
+
+	if filter.MinValue != nil && filter.MaxValue != nil && *filter.MinValue > *filter.MaxValue {
+		return nil, errors.New("Parameter minValue must not be greater than maxValue")
+	}
+
+	if spent := r.FormValue("spent"); spent != "" {
+		value, err := strconv.ParseBool(spent)
+		if err != nil {
+			return nil, errors.New("Parameter spent must be true or false")
+		}
+		filter.Spent = &value
+	}
+
+	switch r.FormValue("order") {
+	case "", "desc":
+		filter.Descending = true
+	case "asc":
+		filter.Descending = false
+	default:
+		return nil, errors.New("Parameter order must be asc or desc")
+	}
+
+	if cursor := r.FormValue("cursor"); cursor != "" {
+		if filter.After, err = repository.ParseCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.Limit, err = limitParam(r); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// acceptedTransaction is a transaction returned by CreateTransactions with the receipt signed by the
+// operator, which client.VerifyReceipt checks.
+type acceptedTransaction struct {
+	model.Transaction
+	Receipt *model.Receipt `json:"receipt,omitempty"`
 }
 
-// CreateTransactions submit new transactions to create. All transactions must be valid.
+// CreateTransactions submit new transactions to create. All transactions must be valid, the error of a
+// rejected transaction has the index of the transaction in the batch. A batch sent again with the same
+// Idempotency-Key header, or whose transactions are all saved already, returns the saved transactions.
+// Each transaction has the receipt of its acceptance.
 func CreateTransactions(w http.ResponseWriter, r *http.Request) {
 	// Get Transaction from body
 	var transactions []model.Transaction
 	err := json.NewDecoder(r.Body).Decode(&transactions)
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
-	// Add Transaction
-	service.AddTransactions(transactions)
+	// Add Transaction, a repeated submission returns the saved transactions
+	transactions, receipts, replayed, err := service.SubmitTransactions(r.Header.Get("Idempotency-Key"), transactions)
 
-	// Return confirmed Transaction
-	json.NewEncoder(w).Encode(transactions)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	// Return the accepted transactions, pending until they are confirmed, with their receipts
+	response := make([]acceptedTransaction, len(transactions))
+	for i := range transactions {
+		response[i] = acceptedTransaction{Transaction: transactions[i], Receipt: receipts[i]}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ValidateTransactions checks new transactions like CreateTransactions without saving them. It returns the
+// result of every rule for each transaction, and the fee and change of the batch.
+func ValidateTransactions(w http.ResponseWriter, r *http.Request) {
+	var transactions []model.Transaction
+	err := json.NewDecoder(r.Body).Decode(&transactions)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	report, err := service.ValidateTransactions(transactions)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
 }
 
-// GetTransaction returns transaction by ID (Signature).
+// GetTransaction returns transaction by ID (TxID).
 func GetTransaction(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	signature := params["id"]
+	txID := params["id"]
 
-	transaction, err := repository.GetTransaction(signature, true)
+	transaction, err := service.GetTransaction(txID)
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(transaction)
 }
 
-// GetTransactionHistory returns the current transaction and the history (all previous transactions).
+// GetTransactionStatus returns whether the transaction is pending (in the mempool), confirmed, or dropped
+// from the mempool with the reason.
+func GetTransactionStatus(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	txID := params["id"]
+
+	status, err := service.GetTransactionStatus(txID)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetTransactionProof returns the proof that the transaction is in a block, which client.VerifyTransactionProof checks.
+func GetTransactionProof(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	txID := params["id"]
+
+	proof, err := service.GetTransactionProof(txID)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(proof)
+}
+
+// GetTransactionHistory returns the current transaction and the history (the previous transactions of the inputs back to GENESIS).
+// The optional depth parameter limits the number of previous transactions (at most Config.MaxWalkDepth), limit and cursor select the page.
 func GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
-	// params := mux.Vars(r)
-	// signature := params["id"]
+	walkTransactions(w, r, service.GetTransactionHistory)
+}
+
+// GetTransactionDescendants returns the current transaction and all the transactions spending its outputs, recursively.
+// The optional depth parameter limits the number of steps from the transaction (at most Config.MaxWalkDepth), limit and cursor select the page.
+func GetTransactionDescendants(w http.ResponseWriter, r *http.Request) {
+	walkTransactions(w, r, service.GetTransactionDescendants)
+}
 
-	transactions, err := repository.GetTransactions() //TODO: Change to get history for specific transaction
+// walkTransactions parses the parameters of a walk of the transaction graph and writes the page.
+func walkTransactions(w http.ResponseWriter, r *http.Request, walk func(txID string, depth int, cursor string, limit int) ([]model.Transaction, string, error)) {
+	params := mux.Vars(r)
+	txID := params["id"]
 
+	depth, err := intParam(r, "depth", -1)
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(transactions)
+	limit, err := limitParam(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	transactions, next, err := walk(txID, depth, r.FormValue("cursor"), limit)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeTransactionPage(w, transactions, next)
+}
+
+// writeTransactionPage streams a page of transactions as {"transactions": [...], "next": cursor}.
+// Each transaction is sent to the client as soon as it is encoded.
+func writeTransactionPage(w http.ResponseWriter, transactions []model.Transaction, next string) {
+	flusher, _ := w.(http.Flusher)
+
+	io.WriteString(w, `{"transactions":[`)
+
+	for i := range transactions {
+		data, err := json.Marshal(&transactions[i])
+		if err != nil {
+			return
+		}
+
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		w.Write(data)
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	cursor, _ := json.Marshal(next)
+	io.WriteString(w, `],"next":`+string(cursor)+"}\n")
+}
+
+// intParam parses an optional integer parameter.
+func intParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("Parameter " + name + " must be an integer")
+	}
+
+	return result, nil
+}
+
+// int64Param parses an optional 64-bit integer parameter, nil if it is not set.
+func int64Param(r *http.Request, name string) (*int64, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	result, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, errors.New("Parameter " + name + " must be an integer")
+	}
+
+	return &result, nil
+}
+
+// timeParam parses an optional RFC 3339 time parameter, the zero time if it is not set.
+func timeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	result, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, errors.New("Parameter " + name + " must be an RFC 3339 time")
+	}
+
+	return result, nil
+}
+
+// limitParam parses the optional page size (1-100, 25 by default).
+func limitParam(r *http.Request) (int, error) {
+	limit, err := intParam(r, "limit", 25)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > 100 {
+		return 0, errors.New("Parameter limit must be between 1 and 100")
+	}
+
+	return limit, nil
 }
 
 // CreateGenesisTransaction creates a new genesis transaction. For testing use only.
@@ -85,7 +351,7 @@ func CreateGenesisTransaction(w http.ResponseWriter, r *http.Request) {
 	t, err := service.CreateGenesisTransaction()
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
@@ -94,14 +360,19 @@ func CreateGenesisTransaction(w http.ResponseWriter, r *http.Request) {
 
 // TransferFromGenesisAccount transfer a specified amount to a wallet from the Genesis wallet. For testing use only.
 func TransferFromGenesisAccount(w http.ResponseWriter, r *http.Request) {
-	signature := r.FormValue("signature")
+	txID := r.FormValue("txId")
 	sendTo := r.FormValue("sendTo")
-	amount, _ := strconv.ParseInt(r.FormValue("amount"), 10, 64)
+	amount, err := strconv.ParseInt(r.FormValue("amount"), 10, 64)
+
+	if err != nil {
+		writeError(w, errors.New("Parameter amount must be an integer"))
+		return
+	}
 
-	transactions, err := service.TransferFromGenesisAccount(signature, sendTo, amount)
+	transactions, err := service.TransferFromGenesisAccount(txID, sendTo, amount)
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
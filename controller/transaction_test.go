@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// failingRepository fails every read, like a repository which lost the connection to the database.
+type failingRepository struct {
+	*repository.MemoryRepository
+}
+
+func (r failingRepository) GetTransaction(txID string) (*model.Transaction, error) {
+	return nil, errors.New("Connection refused")
+}
+
+// serve sends the request to the transaction routes and returns the response.
+func serve(method string, url string, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	InitTransactionController(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+	return w
+}
+
+// payment returns the JSON of a batch of one transaction spending the output of the genesis transaction.
+func payment(genesis *model.Transaction, signed bool) string {
+	wallet, _ := model.NewWallet()
+	t := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesis.Outputs[0].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: genesis.Outputs[0].Value}},
+	}
+	if signed {
+		service.SignInputs(&t, config.InitConfig().GenesisPrivKey)
+	}
+
+	data, _ := json.Marshal([]model.Transaction{t})
+	return string(data)
+}
+
+func TestErrorStatus(t *testing.T) {
+	service.InitService(repository.NewMemoryRepository())
+	genesis, _ := service.CreateGenesisTransaction()
+	spent, _ := service.CreateGenesisTransaction()
+	service.TransferFromGenesisAccount(spent.TxID, genesis.Outputs[0].ToAddress, 100)
+
+	for _, c := range []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+		code   string
+	}{
+		{"invalid JSON", "POST", "/transactions", "{", http.StatusBadRequest, service.CodeInvalidRequest},
+		{"empty batch", "POST", "/transactions", "[]", http.StatusBadRequest, service.CodeInvalidRequest},
+		{"empty validation", "POST", "/transactions/validate", "[]", http.StatusBadRequest, service.CodeInvalidRequest},
+		{"invalid limit", "GET", "/transactions?limit=x", "", http.StatusBadRequest, service.CodeInvalidRequest},
+		{"rule failed", "POST", "/transactions", payment(genesis, false), http.StatusUnprocessableEntity, service.CodeInvalidSignature},
+		{"unknown transaction", "GET", "/transactions/unknown", "", http.StatusNotFound, service.CodeNotFound},
+		{"already spent", "POST", "/transactions", payment(spent, true), http.StatusConflict, service.CodeAlreadySpent},
+	} {
+		w := serve(c.method, c.url, c.body)
+
+		var response errorResponse
+		json.NewDecoder(w.Body).Decode(&response)
+
+		if w.Code != c.status || response.Code != c.code {
+			t.Error("Error response does not match:", c.name, w.Code, response)
+		}
+	}
+
+	if w := serve("POST", "/transactions", payment(genesis, true)); w.Code != http.StatusCreated {
+		t.Error("Transaction not created:", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorStatusStorageUnavailable(t *testing.T) {
+	service.InitService(failingRepository{repository.NewMemoryRepository()})
+	defer service.InitService(repository.NewMemoryRepository())
+
+	w := serve("GET", "/transactions/a", "")
+
+	var response errorResponse
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if w.Code != http.StatusServiceUnavailable || response.Code != service.CodeStorageUnavailable {
+		t.Error("Error response does not match:", w.Code, response)
+	}
+}
@@ -11,26 +11,26 @@ import (
 
 // InitWalletController initializes the controller.
 func InitWalletController(router *mux.Router) {
-	router.HandleFunc("/wallet", GetWallet).Methods("GET")
 	router.HandleFunc("/wallet/create", CreateWallet).Methods("GET")
+	router.HandleFunc("/wallets/{address:.+}", GetWallet).Methods("GET") // Base64 addresses may contain '/'
 }
 
-// GetWallet returns the wallet by public key.
+// GetWallet returns the wallet summary by public key (address).
 func GetWallet(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	pubKey := params["id"]
+	pubKey := params["address"]
 
 	wallet, err := service.GetWallet(pubKey)
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
 	err = json.NewEncoder(w).Encode(wallet)
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 }
@@ -40,14 +40,14 @@ func CreateWallet(w http.ResponseWriter, r *http.Request) {
 	wallet, err := model.NewWallet()
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 
 	err = json.NewEncoder(w).Encode(wallet)
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, err)
 		return
 	}
 }
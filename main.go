@@ -1,22 +1,97 @@
 package main
 
 import (
+	"context"
+	"cryptocoin-server/config"
+	"cryptocoin-server/controller"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/service"
 	"fmt"
 	"log"
 	"net/http"
-	"cryptocoin-server/config"
-	"cryptocoin-server/controller"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
 	config := config.InitConfig()
+
+	repo, err := repository.InitRepository(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repo.Close()
+
+	service.InitService(repo)
+
+	// Commands run against the ledger instead of starting the server
+	if len(os.Args) > 1 {
+		ok, err := runCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			log.Print(err)
+		}
+		if !ok || err != nil {
+			repo.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
 	router := mux.NewRouter()
+	router.SkipClean(true) // Keep "//" of Base64 addresses in paths
 
 	controller.InitTransactionController(router)
 	controller.InitWalletController(router)
+	controller.InitFeeController(router)
+	controller.InitBlockController(router)
+	controller.InitMiningController(router)
+	controller.InitLogController(router)
+	controller.InitHealthController(router)
+
+	server := &http.Server{Addr: config.Port, Handler: router}
+
+	// Pending transactions are confirmed until the server stops, then the rest is confirmed before the repository is closed
+	stopMempool := service.StartMempool(config.MempoolInterval)
+	defer stopMempool()
+
+	// Stop accepting requests on shutdown and let the running ones finish before the repository is closed
+	stopped := make(chan bool)
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		close(stopped)
+	}()
 
 	fmt.Println("Started server http://localhost" + config.Port)
-	log.Fatal(http.ListenAndServe(config.Port, router))
+	err = server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Print(err)
+		return
+	}
+
+	<-stopped
+
+	fmt.Println("Stopped server")
+}
+
+// runCommand runs a command of the server binary with its arguments. It returns false if the command
+// found a problem.
+func runCommand(command string, args []string) (bool, error) {
+	switch command {
+	case "check-timestamps":
+		return checkTimestamps()
+	case "verify-ledger":
+		return verifyLedger(args)
+	}
+
+	return false, fmt.Errorf("Unknown command %q", command)
 }
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// Versions of the block header encoding
+const (
+	BlockVersion     byte = 1 // Header without proof of work
+	BlockVersionWork byte = 2 // Header with a target and a nonce (see MeetsTarget)
+)
+
+// ZeroHash is the previous hash of the first block.
+const ZeroHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// BlockHeader chains a block to the previous one and commits to its transactions.
+type BlockHeader struct {
+	Height     int64     `json:"height"`           // Number of blocks before the block
+	PrevHash   string    `json:"prevHash"`         // Hash of the previous block, ZeroHash for the first block
+	MerkleRoot string    `json:"merkleRoot"`       // Hex encoded Merkle root of the TxIDs (see MerkleRoot)
+	Timestamp  time.Time `json:"timestamp"`        // Time the block was sealed
+	Target     string    `json:"target,omitempty"` // Hex encoded proof of work target, empty without proof of work
+	Nonce      uint64    `json:"nonce,omitempty"`  // Makes the hash of the header meet the target
+}
+
+// Block groups the transactions confirmed together, in the order they were saved to the ledger.
+type Block struct {
+	Hash   string      `json:"hash"` // Hex encoded SHA256 hash of the header
+	Header BlockHeader `json:"header"`
+	TxIDs  []string    `json:"txIds"`
+}
+
+// NewBlock seals the transactions into the block following previous, or into the first block if
+// previous is nil. The timestamp is the current time, but not before the previous block.
+func NewBlock(previous *Block, txIDs []string) *Block {
+	b := new(Block)
+	b.Header.PrevHash = ZeroHash
+	b.Header.Timestamp = time.Now().UTC().Round(0)
+
+	if previous != nil {
+		b.Header.Height = previous.Header.Height + 1
+		b.Header.PrevHash = previous.Hash
+		if previous.Header.Timestamp.After(b.Header.Timestamp) {
+			b.Header.Timestamp = previous.Header.Timestamp
+		}
+	}
+
+	b.TxIDs = txIDs
+	b.Header.MerkleRoot = TxIDsRoot(txIDs)
+	b.Hash = b.Header.Hash()
+	return b
+}
+
+// TxIDsRoot returns the hex encoded Merkle root of the TxIDs, the leaves are the TxIDs as strings.
+func TxIDsRoot(txIDs []string) string {
+	leaves := make([][]byte, len(txIDs))
+	for i, txID := range txIDs {
+		leaves[i] = []byte(txID)
+	}
+	return hex.EncodeToString(MerkleRoot(leaves))
+}
+
+// Serialize returns the encoding of the header which is hashed, with the integers big endian and the
+// strings with a length prefix of 4 bytes (uint32), like the transaction encoding:
+//
+//	version     1 byte   BlockVersion (0x01), or BlockVersionWork (0x02) if the header has a target
+//	height      8 bytes  int64
+//	prevHash             string
+//	merkleRoot           string
+//	timestamp   8 bytes  int64, Unix time in nanoseconds
+//	target               string, only in version 2
+//	nonce       8 bytes  uint64, only in version 2
+//
+// The nonce is last, so a miner only changes the last 8 bytes.
+func (header *BlockHeader) Serialize() []byte {
+	var data bytes.Buffer
+
+	if header.Target == "" {
+		data.WriteByte(BlockVersion)
+	} else {
+		data.WriteByte(BlockVersionWork)
+	}
+
+	binary.Write(&data, binary.BigEndian, header.Height)
+	writeString(&data, header.PrevHash)
+	writeString(&data, header.MerkleRoot)
+	binary.Write(&data, binary.BigEndian, header.Timestamp.UnixNano())
+
+	if header.Target != "" {
+		writeString(&data, header.Target)
+		binary.Write(&data, binary.BigEndian, header.Nonce)
+	}
+
+	return data.Bytes()
+}
+
+// Hash returns the hex encoded SHA256 hash of the encoding of the header.
+func (header *BlockHeader) Hash() string {
+	hash := sha256.Sum256(header.Serialize())
+	return hex.EncodeToString(hash[:])
+}
+
+// Valid returns true if the hash of the block is the hash of its header, the Merkle root is the root
+// of its TxIDs, and the hash meets the target of a block with proof of work.
+func (block *Block) Valid() bool {
+	if block.Header.Target != "" && !block.Header.MeetsTarget() {
+		return false
+	}
+
+	return block.Hash == block.Header.Hash() && block.Header.MerkleRoot == TxIDsRoot(block.TxIDs)
+}
+
+// Follows returns true if the block is the next block after previous, or the first block if previous
+// is nil.
+func (block *Block) Follows(previous *Block) bool {
+	if previous == nil {
+		return block.Header.Height == 0 && block.Header.PrevHash == ZeroHash
+	}
+
+	return block.Header.Height == previous.Header.Height+1 && block.Header.PrevHash == previous.Hash
+}
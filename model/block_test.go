@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewBlock(t *testing.T) {
+	first := NewBlock(nil, []string{"a", "b"})
+
+	if !first.Valid() || !first.Follows(nil) || first.Header.PrevHash != ZeroHash {
+		t.Error("First block is not valid:", first)
+	}
+
+	second := NewBlock(first, []string{"c"})
+
+	if !second.Valid() || !second.Follows(first) || second.Follows(nil) || second.Header.Height != 1 {
+		t.Error("Second block does not follow the first:", second)
+	}
+
+	if second.Header.Timestamp.Before(first.Header.Timestamp) {
+		t.Error("Timestamp is before the previous block:", second.Header.Timestamp)
+	}
+
+	// Changing a TxID or the header changes the hashes
+	second.TxIDs = []string{"d"}
+	if second.Valid() {
+		t.Error("Block with another TxID is valid")
+	}
+
+	first.Header.Timestamp = first.Header.Timestamp.Add(time.Nanosecond)
+	if first.Valid() {
+		t.Error("Block with another header is valid")
+	}
+}
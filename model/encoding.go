@@ -0,0 +1,127 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// EncodingVersion is the version of the canonical transaction encoding.
+const EncodingVersion byte = 2
+
+// Serialize returns the canonical encoding of the signed fields of the transaction. All the
+// integers are big endian and the strings are UTF-8 with a length prefix of 4 bytes (uint32):
+//
+//	version       1 byte   EncodingVersion (0x02)
+//	timestamp     8 bytes  int64, Unix time in nanoseconds (independent of the time zone)
+//	inputs        4 bytes  uint32 count, followed by each input:
+//	  txId                 string, TxID of the spent output
+//	  index       4 bytes  uint32, index of the spent output
+//	  pubKey               string
+//	outputs       4 bytes  uint32 count, followed by each output:
+//	  toAddress            string
+//	  value       8 bytes  int64
+//	collects      4 bytes  uint32 count, followed by each TxID collected by a fee transaction:
+//	  txId                 string
+//
+// The collects are only encoded if the transaction collects fees, so the encoding of the other
+// transactions does not depend on them. Every input signature is the ECDSA signature of the SHA256 hash
+// of this encoding (see Hash), which is also the ID of the transaction (see ID). Version 1 is the
+// encoding of a Record. Test vectors for other implementations are in testdata/canonical_vectors.json.
+func (transaction *Transaction) Serialize() ([]byte, error) {
+	size := 1 + 8 + 4 + 4
+	for _, input := range transaction.Inputs {
+		if input.Outpoint.Index < 0 || uint64(input.Outpoint.Index) > 0xFFFFFFFF {
+			return nil, errors.New("Output index is out of range")
+		}
+		size += 4 + len(input.Outpoint.TxID) + 4 + 4 + len(input.PubKey)
+	}
+	for _, output := range transaction.Outputs {
+		size += 4 + len(output.ToAddress) + 8
+	}
+	if len(transaction.Collects) > 0 {
+		size += 4
+	}
+	for _, txID := range transaction.Collects {
+		size += 4 + len(txID)
+	}
+
+	var data bytes.Buffer
+	data.Grow(size)
+
+	data.WriteByte(EncodingVersion)
+	binary.Write(&data, binary.BigEndian, transaction.Timestamp.UnixNano())
+
+	binary.Write(&data, binary.BigEndian, uint32(len(transaction.Inputs)))
+	for _, input := range transaction.Inputs {
+		if err := writeString(&data, input.Outpoint.TxID); err != nil {
+			return nil, err
+		}
+		binary.Write(&data, binary.BigEndian, uint32(input.Outpoint.Index))
+		if err := writeString(&data, input.PubKey); err != nil {
+			return nil, err
+		}
+	}
+
+	binary.Write(&data, binary.BigEndian, uint32(len(transaction.Outputs)))
+	for _, output := range transaction.Outputs {
+		if err := writeString(&data, output.ToAddress); err != nil {
+			return nil, err
+		}
+		binary.Write(&data, binary.BigEndian, output.Value)
+	}
+
+	if len(transaction.Collects) > 0 {
+		binary.Write(&data, binary.BigEndian, uint32(len(transaction.Collects)))
+		for _, txID := range transaction.Collects {
+			if err := writeString(&data, txID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return data.Bytes(), nil
+}
+
+// Size returns the size of the canonical encoding in bytes, the fee of the transaction depends on it.
+func (transaction *Transaction) Size() (int, error) {
+	data, err := transaction.Serialize()
+	return len(data), err
+}
+
+// Typical lengths of the strings of the encoding
+const (
+	txIDLength   = 64 // Hex encoded SHA256 hash
+	pubKeyLength = 88 // Base64 encoded P-256 point (X and Y)
+)
+
+// EstimateSize returns the typical size of the canonical encoding of a transaction with the number of
+// inputs and outputs.
+func EstimateSize(inputs int, outputs int) int {
+	return 1 + 8 + 4 + inputs*(4+txIDLength+4+4+pubKeyLength) + 4 + outputs*(4+pubKeyLength+8)
+}
+
+// Hash returns the SHA256 hash of the canonical encoding, which is signed by the owner of every input.
+func (transaction *Transaction) Hash() ([]byte, error) {
+	data, err := transaction.Serialize()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hashBytes := sha256.Sum256(data)
+
+	return hashBytes[:], nil
+}
+
+// writeString writes the length of the string and the string.
+func writeString(data *bytes.Buffer, s string) error {
+	if uint64(len(s)) > 0xFFFFFFFF {
+		return errors.New("Transaction field is too long")
+	}
+
+	binary.Write(data, binary.BigEndian, uint32(len(s)))
+	data.WriteString(s)
+	return nil
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+type canonicalVectors struct {
+	Vectors []struct {
+		Name        string      `json:"name"`
+		Transaction Transaction `json:"transaction"`
+		Encoding    string      `json:"encoding"`
+		Hash        string      `json:"hash"`
+	} `json:"vectors"`
+}
+
+func TestCanonicalVectors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/canonical_vectors.json")
+	if err != nil {
+		t.Fatal("Test vectors not found:", err)
+	}
+
+	var vectors canonicalVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal("Test vectors are invalid:", err)
+	}
+
+	for _, v := range vectors.Vectors {
+		encoding, err := v.Transaction.Serialize()
+		if hex.EncodeToString(encoding) != v.Encoding || err != nil {
+			t.Error("Encoding does not match:", v.Name, hex.EncodeToString(encoding), err)
+		}
+
+		hash, err := v.Transaction.Hash()
+		if hex.EncodeToString(hash) != v.Hash || err != nil {
+			t.Error("Hash does not match:", v.Name, hex.EncodeToString(hash), err)
+		}
+
+		txID, err := v.Transaction.ID()
+		if txID != v.Hash || txID != v.Transaction.TxID || err != nil {
+			t.Error("TxID does not match:", v.Name, txID, err)
+		}
+	}
+}
+
+func TestRecordVectors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/record_vectors.json")
+	if err != nil {
+		t.Fatal("Test vectors not found:", err)
+	}
+
+	var vectors struct {
+		Vectors []struct {
+			Name     string `json:"name"`
+			Record   Record `json:"transaction"`
+			Encoding string `json:"encoding"`
+			Hash     string `json:"hash"`
+		} `json:"vectors"`
+	}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal("Test vectors are invalid:", err)
+	}
+
+	for _, v := range vectors.Vectors {
+		encoding, err := v.Record.Serialize()
+		if hex.EncodeToString(encoding) != v.Encoding || err != nil {
+			t.Error("Encoding does not match:", v.Name, hex.EncodeToString(encoding), err)
+		}
+
+		hash, err := v.Record.Hash()
+		if hex.EncodeToString(hash) != v.Hash || err != nil {
+			t.Error("Hash does not match:", v.Name, hex.EncodeToString(hash), err)
+		}
+	}
+}
+
+func TestSerializeVersion(t *testing.T) {
+	t1 := Transaction{Outputs: []Output{{Value: 1, ToAddress: "123"}}}
+
+	data, _ := t1.Serialize()
+
+	if data[0] != EncodingVersion {
+		t.Error("Encoding does not start with the version:", data)
+	}
+}
+
+func TestSerializeTimeZone(t *testing.T) {
+	now := time.Now()
+	t1 := Transaction{Outputs: []Output{{Value: 1, ToAddress: "123"}}, Timestamp: now.UTC()}
+	t2 := Transaction{Outputs: []Output{{Value: 1, ToAddress: "123"}}, Timestamp: now.In(time.FixedZone("UTC+2", 2*60*60))}
+
+	h1, _ := t1.Hash()
+	h2, _ := t2.Hash()
+
+	if !bytes.Equal(h1, h2) {
+		t.Error("Hash depends on the time zone:", h1, h2)
+	}
+}
+
+func TestSerializeFieldBoundaries(t *testing.T) {
+	// Moving characters between string fields must change the encoding
+	t1 := Transaction{Inputs: []Input{{Outpoint: Outpoint{TxID: "ab"}, PubKey: "c"}}}
+	t2 := Transaction{Inputs: []Input{{Outpoint: Outpoint{TxID: "a"}, PubKey: "bc"}}}
+
+	bytes1, _ := t1.Serialize()
+	bytes2, _ := t2.Serialize()
+
+	if bytes.Equal(bytes1, bytes2) {
+		t.Error("Encodings of different transactions match:", bytes1)
+	}
+
+	// Moving an output to the inputs must change the encoding
+	t3 := Transaction{Outputs: []Output{{ToAddress: "a"}, {ToAddress: "b"}}}
+	t4 := Transaction{Inputs: []Input{{PubKey: "a"}}, Outputs: []Output{{ToAddress: "b"}}}
+
+	bytes3, _ := t3.Serialize()
+	bytes4, _ := t4.Serialize()
+
+	if bytes.Equal(bytes3, bytes4) {
+		t.Error("Encodings of different transactions match:", bytes3)
+	}
+}
+
+func TestSerializeCollects(t *testing.T) {
+	// Fee transactions with the same timestamp and total must not have the same TxID
+	t1 := Transaction{Outputs: []Output{{ToAddress: "a", Value: 10}}, Collects: []string{"b"}}
+	t2 := Transaction{Outputs: []Output{{ToAddress: "a", Value: 10}}, Collects: []string{"c"}}
+	t3 := Transaction{Outputs: []Output{{ToAddress: "a", Value: 10}}}
+
+	id1, _ := t1.ID()
+	id2, _ := t2.ID()
+	id3, _ := t3.ID()
+
+	if id1 == id2 || id1 == id3 {
+		t.Error("TxID does not depend on the collected TxIDs:", id1, id2, id3)
+	}
+}
+
+func TestSerializeOutputIndex(t *testing.T) {
+	t1 := Transaction{Inputs: []Input{{Outpoint: Outpoint{TxID: "a", Index: -1}}}}
+
+	if _, err := t1.Serialize(); err == nil {
+		t.Error("Negative output index accepted")
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	t1 := Transaction{
+		Inputs:  []Input{{Outpoint: Outpoint{TxID: strings.Repeat("a", txIDLength)}, PubKey: strings.Repeat("b", pubKeyLength)}},
+		Outputs: []Output{{ToAddress: strings.Repeat("c", pubKeyLength)}, {ToAddress: strings.Repeat("d", pubKeyLength)}},
+	}
+
+	if size, err := t1.Size(); size != EstimateSize(1, 2) || err != nil {
+		t.Error("Estimated size does not match:", size, EstimateSize(1, 2), err)
+	}
+}
@@ -0,0 +1,12 @@
+package model
+
+// FeeEstimate is the minimum fee of a transaction with the number of inputs and outputs.
+type FeeEstimate struct {
+	Inputs          int    `json:"inputs"`
+	Outputs         int    `json:"outputs"`
+	Size            int    `json:"size"` // Typical size of the canonical encoding in bytes
+	FeePerByte      int64  `json:"feePerByte"`
+	FeePerInput     int64  `json:"feePerInput"`
+	Fee             int64  `json:"fee"`
+	OperatorAddress string `json:"operatorAddress"` // Address the fees are sent to
+}
@@ -0,0 +1,28 @@
+package model
+
+// Codes of the violations found by the verification of the ledger (see LedgerReport)
+const (
+	ViolationInvalidSignature  = "invalid_signature"
+	ViolationMissingInput      = "missing_input"
+	ViolationDoubleSpend       = "double_spend"
+	ViolationValueNotConserved = "value_not_conserved"
+	ViolationSupplyMismatch    = "supply_mismatch"
+	ViolationInvalidGenesis    = "invalid_genesis"
+)
+
+// Violation is a rule of the ledger broken by a saved transaction.
+type Violation struct {
+	TxID    string `json:"txId,omitempty"` // Empty for a violation of the whole ledger
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// LedgerReport is the result of the verification of every transaction saved in the ledger.
+type LedgerReport struct {
+	Transactions      int         `json:"transactions"`
+	GenesisAllocation int64       `json:"genesisAllocation"` // Total value of the outputs of the genesis transactions
+	Supply            int64       `json:"supply"`            // Total value of the unspent outputs
+	Violations        []Violation `json:"violations"`
+	LostPrecision     []string    `json:"lostPrecision,omitempty"` // TxIDs of the transactions stored in seconds whose signature does not verify
+	Quarantined       []string    `json:"quarantined,omitempty"`   // TxIDs of the transactions quarantined
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// TreeHead is the signed tree head of the transaction log: the root of the Merkle tree of the first
+// TreeSize TxIDs of the log at the time, signed by the operator.
+type TreeHead struct {
+	TreeSize  int64     `json:"treeSize"`
+	Timestamp time.Time `json:"timestamp"` // Millisecond precision
+	RootHash  string    `json:"rootHash"`  // Hex encoded root of the Merkle tree (see MerkleTree)
+	PubKey    string    `json:"pubKey"`    // Public key of the operator
+	Signature string    `json:"signature"` // Operator signature of the hash of the encoding (see Serialize)
+}
+
+// Serialize returns the encoding of the tree head which is signed, the TreeHeadSignature of RFC 6962
+// (section 3.5), with the integers big endian:
+//
+//	version         1 byte   0 (v1)
+//	signature type  1 byte   1 (tree_hash)
+//	timestamp       8 bytes  uint64, Unix time in milliseconds
+//	tree size       8 bytes  uint64
+//	root hash      32 bytes
+func (head *TreeHead) Serialize() []byte {
+	var data bytes.Buffer
+
+	data.WriteByte(0)
+	data.WriteByte(1)
+	binary.Write(&data, binary.BigEndian, uint64(head.Timestamp.UnixNano()/int64(time.Millisecond)))
+	binary.Write(&data, binary.BigEndian, uint64(head.TreeSize))
+
+	root, _ := hex.DecodeString(head.RootHash)
+	data.Write(root)
+
+	return data.Bytes()
+}
+
+// Hash returns the SHA256 hash of the encoding, which is signed by the operator.
+func (head *TreeHead) Hash() []byte {
+	hash := sha256.Sum256(head.Serialize())
+	return hash[:]
+}
+
+// ConsistencyProof proves that the log of size From is a prefix of the log of size To.
+type ConsistencyProof struct {
+	From  int64    `json:"from"`
+	To    int64    `json:"to"`
+	Proof []string `json:"proof"` // Hex encoded hashes (RFC 6962, section 2.1.2)
+}
+
+// InclusionProof proves that the transaction is in the log of size TreeSize.
+type InclusionProof struct {
+	TxID      string   `json:"txId"`
+	LeafIndex int64    `json:"leafIndex"` // Position of the TxID in the log
+	TreeSize  int64    `json:"treeSize"`
+	Path      []string `json:"path"` // Hex encoded audit path, from the leaf to the root
+}
@@ -0,0 +1,297 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Prefixes of the hashes of the Merkle tree, so a leaf can not be taken for a node (RFC 6962, section 2.1)
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+)
+
+// MerkleTree is an append-only Merkle tree, as defined by RFC 6962: a leaf is hashed as SHA256(0x00 || leaf)
+// and a node as SHA256(0x01 || left || right). The left subtree of n leaves has the largest power of two
+// smaller than n leaves, the last leaf is never duplicated. The hashes of the complete subtrees never
+// change, so they are kept and the root and the proofs of any size only hash the incomplete subtrees.
+type MerkleTree struct {
+	levels [][][]byte // levels[i][j] is the hash of the complete subtree of 2^i leaves from leaf j * 2^i
+}
+
+// NewMerkleTree creates the tree of the leaves.
+func NewMerkleTree(leaves [][]byte) *MerkleTree {
+	t := new(MerkleTree)
+	for _, leaf := range leaves {
+		t.Append(leaf)
+	}
+	return t
+}
+
+// Append adds the leaf to the tree, and the hashes of the subtrees it completes.
+func (t *MerkleTree) Append(leaf []byte) {
+	hash := merkleLeafHash(leaf)
+
+	for level := 0; ; level++ {
+		if level == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+
+		t.levels[level] = append(t.levels[level], hash)
+		n := len(t.levels[level])
+		if n%2 == 1 {
+			return
+		}
+
+		hash = merkleNodeHash(t.levels[level][n-2], t.levels[level][n-1])
+	}
+}
+
+// Size returns the number of leaves.
+func (t *MerkleTree) Size() int {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return len(t.levels[0])
+}
+
+// Root returns the root hash of the tree of the first size leaves. The root of no leaves is SHA256 of the
+// empty string.
+func (t *MerkleTree) Root(size int) ([]byte, error) {
+	if size < 0 || size > t.Size() {
+		return nil, errors.New("Tree size must not be greater than the number of leaves")
+	}
+
+	return t.hash(0, size), nil
+}
+
+// InclusionProof returns the audit path of the leaf at index in the tree of the first size leaves: the
+// hashes of the subtrees which are needed with the leaf to compute the root, from the leaf to the root.
+func (t *MerkleTree) InclusionProof(index int, size int) ([][]byte, error) {
+	if size < 0 || size > t.Size() {
+		return nil, errors.New("Tree size must not be greater than the number of leaves")
+	}
+
+	if index < 0 || index >= size {
+		return nil, errors.New("Leaf index must be less than the tree size")
+	}
+
+	return t.path(index, 0, size), nil
+}
+
+// ConsistencyProof returns the proof that the tree of the first from leaves is a prefix of the tree of
+// the first to leaves (RFC 6962, section 2.1.2).
+func (t *MerkleTree) ConsistencyProof(from int, to int) ([][]byte, error) {
+	if to > t.Size() {
+		return nil, errors.New("Tree size must not be greater than the number of leaves")
+	}
+
+	if from < 0 || from > to {
+		return nil, errors.New("First tree size must not be greater than the second")
+	}
+
+	if from == 0 {
+		return [][]byte{}, nil
+	}
+
+	return t.subproof(from, 0, to, true), nil
+}
+
+// hash returns the hash of the subtree of n leaves from leaf start.
+func (t *MerkleTree) hash(start int, n int) []byte {
+	if n == 0 {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+
+	// A complete subtree which is kept
+	if n&(n-1) == 0 && start%n == 0 {
+		level := 0
+		for 1<<level < n {
+			level++
+		}
+		return t.levels[level][start/n]
+	}
+
+	k := merkleSplit(n)
+	return merkleNodeHash(t.hash(start, k), t.hash(start+k, n-k))
+}
+
+// path returns the audit path of the leaf at index in the subtree of n leaves from leaf start.
+func (t *MerkleTree) path(index int, start int, n int) [][]byte {
+	if n == 1 {
+		return [][]byte{}
+	}
+
+	k := merkleSplit(n)
+	if index < k {
+		return append(t.path(index, start, k), t.hash(start+k, n-k))
+	}
+	return append(t.path(index-k, start+k, n-k), t.hash(start, k))
+}
+
+// subproof returns SUBPROOF(m, D[start:start+n], b) of RFC 6962, b is true if the subtree of m leaves is
+// the tree of the first size.
+func (t *MerkleTree) subproof(m int, start int, n int, b bool) [][]byte {
+	if m == n {
+		if b {
+			return [][]byte{}
+		}
+		return [][]byte{t.hash(start, n)}
+	}
+
+	k := merkleSplit(n)
+	if m <= k {
+		return append(t.subproof(m, start, k, b), t.hash(start+k, n-k))
+	}
+	return append(t.subproof(m-k, start+k, n-k, false), t.hash(start, k))
+}
+
+// MerkleRoot returns the root hash of the Merkle tree of the leaves (see MerkleTree).
+func MerkleRoot(leaves [][]byte) []byte {
+	root, _ := NewMerkleTree(leaves).Root(len(leaves))
+	return root
+}
+
+// MerklePath returns the audit path of the leaf at index in the Merkle tree of the leaves (see
+// MerkleTree.InclusionProof), or an empty path if the index is out of range.
+func MerklePath(leaves [][]byte, index int) [][]byte {
+	path, err := NewMerkleTree(leaves).InclusionProof(index, len(leaves))
+	if err != nil {
+		return [][]byte{}
+	}
+	return path
+}
+
+// VerifyMerklePath returns nil if the audit path proves that the leaf at index is in the tree of size
+// leaves with the root (RFC 9162, section 2.1.3.2).
+func VerifyMerklePath(leaf []byte, index int, size int, path [][]byte, root []byte) error {
+	if index < 0 || index >= size {
+		return errors.New("Leaf index must be less than the tree size")
+	}
+
+	fn, sn := index, size-1
+	hash := merkleLeafHash(leaf)
+
+	for _, p := range path {
+		if sn == 0 {
+			return errors.New("Audit path is too long")
+		}
+
+		if fn&1 == 1 || fn == sn {
+			hash = merkleNodeHash(p, hash)
+			// A right-most node without a sibling moves up until it is a right child
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = merkleNodeHash(hash, p)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return errors.New("Audit path is too short")
+	}
+
+	if !bytes.Equal(hash, root) {
+		return errors.New("Audit path does not match the root")
+	}
+
+	return nil
+}
+
+// VerifyConsistency returns nil if the proof shows that the tree of size from with the root fromRoot is a
+// prefix of the tree of size to with the root toRoot (RFC 9162, section 2.1.4.2).
+func VerifyConsistency(from int, to int, fromRoot []byte, toRoot []byte, proof [][]byte) error {
+	if from < 0 || from > to {
+		return errors.New("First tree size must not be greater than the second")
+	}
+
+	// Every tree is consistent with the empty tree, and a tree with itself if the roots are the same
+	if from == 0 || from == to {
+		if len(proof) != 0 {
+			return errors.New("Consistency proof must be empty")
+		}
+		if from == to && !bytes.Equal(fromRoot, toRoot) {
+			return errors.New("Roots of the same tree size do not match")
+		}
+		return nil
+	}
+
+	// The first tree is a complete subtree of the second, its root is the first hash
+	if from&(from-1) == 0 {
+		proof = append([][]byte{fromRoot}, proof...)
+	}
+
+	if len(proof) == 0 {
+		return errors.New("Consistency proof is too short")
+	}
+
+	fn, sn := from-1, to-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return errors.New("Consistency proof is too long")
+		}
+
+		if fn&1 == 1 || fn == sn {
+			fr = merkleNodeHash(c, fr)
+			sr = merkleNodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = merkleNodeHash(sr, c)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return errors.New("Consistency proof is too short")
+	}
+
+	if !bytes.Equal(fr, fromRoot) || !bytes.Equal(sr, toRoot) {
+		return errors.New("Consistency proof does not match the roots")
+	}
+
+	return nil
+}
+
+// merkleLeafHash returns the hash of a leaf.
+func merkleLeafHash(leaf []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+	return hash[:]
+}
+
+// merkleNodeHash returns the hash of a node from the hashes of its children.
+func merkleNodeHash(left []byte, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left...)
+	data = append(data, right...)
+
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// merkleSplit returns the largest power of two smaller than n, for n > 1.
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
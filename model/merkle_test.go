@@ -0,0 +1,132 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+
+	empty := sha256.Sum256(nil)
+	if root := MerkleRoot(nil); !bytes.Equal(root, empty[:]) {
+		t.Error("Root of no leaves does not match:", root)
+	}
+
+	leaf := sha256.Sum256([]byte{0x00, 'a'})
+	if root := MerkleRoot([][]byte{a}); !bytes.Equal(root, leaf[:]) {
+		t.Error("Root of one leaf does not match:", root)
+	}
+
+	// The last leaf of an odd tree is not duplicated
+	ab := merkleNodeHash(merkleLeafHash(a), merkleLeafHash(b))
+	abc := merkleNodeHash(ab, merkleLeafHash(c))
+	if root := MerkleRoot([][]byte{a, b, c}); !bytes.Equal(root, abc) {
+		t.Error("Root of three leaves does not match:", root)
+	}
+
+	if root := MerkleRoot([][]byte{a, b, c, c}); bytes.Equal(root, abc) {
+		t.Error("Root with a duplicated leaf matches:", root)
+	}
+
+	// RFC 6962 test vector: the leaf of the empty string
+	if root := hex.EncodeToString(MerkleRoot([][]byte{{}})); root != "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d" {
+		t.Error("Root of the empty leaf does not match:", root)
+	}
+}
+
+func TestMerklePath(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		var leaves [][]byte
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, []byte(strconv.Itoa(i)))
+		}
+		root := MerkleRoot(leaves)
+
+		for index := range leaves {
+			path := MerklePath(leaves, index)
+
+			if err := VerifyMerklePath(leaves[index], index, size, path, root); err != nil {
+				t.Error("Audit path not verified:", size, index, err)
+			}
+
+			if err := VerifyMerklePath([]byte("x"), index, size, path, root); err == nil {
+				t.Error("Audit path of another leaf verified:", size, index)
+			}
+
+			if err := VerifyMerklePath(leaves[index], index, size, append(path, root), root); err == nil {
+				t.Error("Audit path with an extra hash verified:", size, index)
+			}
+
+			if len(path) > 0 {
+				if err := VerifyMerklePath(leaves[index], index, size, path[:len(path)-1], root); err == nil {
+					t.Error("Truncated audit path verified:", size, index)
+				}
+			}
+
+			if size > 1 {
+				if err := VerifyMerklePath(leaves[index], (index+1)%size, size, path, root); err == nil {
+					t.Error("Audit path at another index verified:", size, index)
+				}
+			}
+		}
+	}
+
+	if err := VerifyMerklePath([]byte("a"), 1, 1, nil, MerkleRoot([][]byte{[]byte("a")})); err == nil {
+		t.Error("Index out of range verified")
+	}
+}
+
+func TestMerkleTreeConsistency(t *testing.T) {
+	var leaves [][]byte
+	for i := 0; i < 17; i++ {
+		leaves = append(leaves, []byte(strconv.Itoa(i)))
+	}
+	tree := NewMerkleTree(leaves)
+
+	for to := 0; to <= len(leaves); to++ {
+		toRoot, _ := tree.Root(to)
+		if !bytes.Equal(toRoot, MerkleRoot(leaves[:to])) {
+			t.Error("Root of the tree size does not match:", to)
+		}
+
+		for from := 0; from <= to; from++ {
+			fromRoot, _ := tree.Root(from)
+			proof, err := tree.ConsistencyProof(from, to)
+			if err != nil {
+				t.Fatal("ConsistencyProof failed:", from, to, err)
+			}
+
+			if err := VerifyConsistency(from, to, fromRoot, toRoot, proof); err != nil {
+				t.Error("Consistency proof not verified:", from, to, err)
+			}
+
+			// A rewritten history is not consistent
+			if from > 0 && from < to {
+				rewritten, _ := NewMerkleTree(append([][]byte{[]byte("x")}, leaves[1:]...)).Root(from)
+				if err := VerifyConsistency(from, to, rewritten, toRoot, proof); err == nil {
+					t.Error("Consistency proof of a rewritten tree verified:", from, to)
+				}
+
+				if err := VerifyConsistency(from, to, fromRoot, toRoot, proof[:len(proof)-1]); err == nil {
+					t.Error("Truncated consistency proof verified:", from, to)
+				}
+
+				if err := VerifyConsistency(from, to, fromRoot, toRoot, append(proof, toRoot)); err == nil {
+					t.Error("Consistency proof with an extra hash verified:", from, to)
+				}
+			}
+		}
+	}
+
+	if _, err := tree.ConsistencyProof(2, 18); err == nil {
+		t.Error("Consistency proof beyond the tree size returned")
+	}
+
+	if _, err := tree.InclusionProof(3, 3); err == nil {
+		t.Error("Inclusion proof of a leaf beyond the tree size returned")
+	}
+}
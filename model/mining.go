@@ -0,0 +1,14 @@
+package model
+
+// MiningStatus is the state of the proof of work miner.
+type MiningStatus struct {
+	Enabled     bool    `json:"enabled"`     // Blocks are sealed with proof of work
+	Threads     int     `json:"threads"`     // Goroutines searching the nonce
+	Mining      bool    `json:"mining"`      // A block is being mined
+	Hashrate    float64 `json:"hashrate"`    // Hashes per second while mining
+	Hashes      uint64  `json:"hashes"`      // Hashes of all the mined blocks
+	BlocksMined int64   `json:"blocksMined"` // Blocks mined since the server started
+	Height      int64   `json:"height"`      // Height of the next block
+	Target      string  `json:"target"`      // Hex encoded target of the next block
+	Difficulty  float64 `json:"difficulty"`  // How many times harder the target is than the easiest target
+}
@@ -0,0 +1,14 @@
+package model
+
+// TransactionProof proves that a transaction is in a block: the audit path from the TxID to the Merkle
+// root of the block, and the header of the block with its hash signed by the operator.
+type TransactionProof struct {
+	TxID      string      `json:"txId"`
+	Index     int         `json:"index"`     // Position of the TxID in the block
+	TreeSize  int         `json:"treeSize"`  // Number of TxIDs in the block
+	Path      []string    `json:"path"`      // Hex encoded audit path, from the leaf to the root
+	BlockHash string      `json:"blockHash"` // Hex encoded SHA256 hash of the header
+	Header    BlockHeader `json:"header"`
+	PubKey    string      `json:"pubKey"`    // Public key of the operator
+	Signature string      `json:"signature"` // Operator signature of the block hash
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// receiptDomain starts the encoding of a receipt, so a signed receipt can not be taken for another
+// message signed by the operator.
+const receiptDomain = "cryptocoin-server receipt v1"
+
+// Acceptance is when the server accepted a transaction. It is saved with the transaction, so the receipt
+// of a transaction can be signed again after it is confirmed.
+type Acceptance struct {
+	AcceptedAt time.Time `json:"acceptedAt"`
+	Sequence   int64     `json:"sequence"` // Sequence number of the acceptance, increasing in the order of acceptance
+}
+
+// Receipt is the operator's signed statement that a transaction was accepted into the mempool: the
+// merchant can prove the payment was accepted even if the transaction is not confirmed yet. It does not
+// state that the transaction will be confirmed: a pending transaction is dropped when it can not be saved,
+// and lost when the server stops before confirming it.
+type Receipt struct {
+	TxID       string    `json:"txId"`
+	AcceptedAt time.Time `json:"acceptedAt"`
+	Sequence   int64     `json:"sequence"`  // Sequence number of the acceptance, it is not the index in the log
+	PubKey     string    `json:"pubKey"`    // Public key of the operator
+	Signature  string    `json:"signature"` // Operator signature of the hash of the encoding (see Serialize)
+}
+
+// Serialize returns the encoding of the receipt which is signed, with the integers big endian:
+//
+//	domain       string  "cryptocoin-server receipt v1"
+//	TxID         string
+//	accepted at  8 bytes  int64, Unix time in nanoseconds
+//	sequence     8 bytes  int64
+//
+// A string is its length (uint32) and its bytes.
+func (receipt *Receipt) Serialize() []byte {
+	var data bytes.Buffer
+
+	writeString(&data, receiptDomain)
+	writeString(&data, receipt.TxID)
+	binary.Write(&data, binary.BigEndian, receipt.AcceptedAt.UnixNano())
+	binary.Write(&data, binary.BigEndian, receipt.Sequence)
+
+	return data.Bytes()
+}
+
+// Hash returns the SHA256 hash of the encoding, which is signed by the operator.
+func (receipt *Receipt) Hash() []byte {
+	hash := sha256.Sum256(receipt.Serialize())
+	return hash[:]
+}
@@ -0,0 +1,156 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"time"
+)
+
+// RecordEncodingVersion is the version of the canonical encoding of a Record.
+const RecordEncodingVersion byte = 1
+
+// Record is a transaction of the ledger before transactions had inputs and outputs. A record spent the
+// whole value of the previous record and sent part of it to one address, the rest of the payment was
+// sent by sibling records with the same timestamp. Records are converted to transactions when the
+// ledger is migrated, and their signatures are still verified with the hash of the record.
+type Record struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	ToAddress string    `json:"toAddress"`
+	Value     int64     `json:"value"`
+	PubKey    string    `json:"pubKey"`
+	PrevTxID  string    `json:"prevTxId"` // TxID of the spent record, or GENESIS
+	Signature string    `json:"signature"`
+}
+
+// Transaction converts the record to a transaction with one output, spending output 0 of the previous
+// record. A GENESIS record has no inputs. The TxID of the record is kept.
+func (record *Record) Transaction() Transaction {
+	t := Transaction{TxID: record.TxID, Timestamp: record.Timestamp}
+	t.Outputs = []Output{{ToAddress: record.ToAddress, Value: record.Value}}
+
+	if record.PrevTxID != "GENESIS" {
+		t.Inputs = []Input{{Outpoint: Outpoint{TxID: record.PrevTxID}, PubKey: record.PubKey, Signature: record.Signature}}
+	}
+
+	return t
+}
+
+// Record returns the record the transaction was converted from. It returns false if the transaction
+// does not have the shape of a converted record (one input of output 0, and one output).
+func (transaction *Transaction) Record() (*Record, bool) {
+	if len(transaction.Inputs) != 1 || len(transaction.Outputs) != 1 || transaction.Inputs[0].Outpoint.Index != 0 {
+		return nil, false
+	}
+
+	input := transaction.Inputs[0]
+	output := transaction.Outputs[0]
+
+	return &Record{
+		TxID:      transaction.TxID,
+		Timestamp: transaction.Timestamp,
+		ToAddress: output.ToAddress,
+		Value:     output.Value,
+		PubKey:    input.PubKey,
+		PrevTxID:  input.Outpoint.TxID,
+		Signature: input.Signature,
+	}, true
+}
+
+// Serialize returns the canonical encoding (version 1) of the signed fields of the record. All the
+// integers are big endian and the strings are UTF-8 with a length prefix:
+//
+//	version       1 byte   RecordEncodingVersion (0x01)
+//	timestamp     8 bytes  int64, Unix time in nanoseconds (independent of the time zone)
+//	toAddress     4 bytes  uint32 length, followed by the string
+//	value         8 bytes  int64
+//	pubKey        4 bytes  uint32 length, followed by the string
+//	prevTxId      4 bytes  uint32 length, followed by the string
+//
+// Test vectors are in testdata/record_vectors.json.
+func (record *Record) Serialize() ([]byte, error) {
+	var data bytes.Buffer
+	data.Grow(1 + 8 + 4 + len(record.ToAddress) + 8 + 4 + len(record.PubKey) + 4 + len(record.PrevTxID))
+
+	data.WriteByte(RecordEncodingVersion)
+	binary.Write(&data, binary.BigEndian, record.Timestamp.UnixNano())
+	if err := writeString(&data, record.ToAddress); err != nil {
+		return nil, err
+	}
+	binary.Write(&data, binary.BigEndian, record.Value)
+	if err := writeString(&data, record.PubKey); err != nil {
+		return nil, err
+	}
+	if err := writeString(&data, record.PrevTxID); err != nil {
+		return nil, err
+	}
+
+	return data.Bytes(), nil
+}
+
+// Hash returns the SHA256 hash of the canonical encoding of the record.
+func (record *Record) Hash() ([]byte, error) {
+	data, err := record.Serialize()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hashBytes := sha256.Sum256(data)
+
+	return hashBytes[:], nil
+}
+
+// SerializeLegacy serializes the signed fields with gob. Records were signed with the hash of
+// this encoding before the canonical encoding, it is only used to verify them.
+func (record *Record) SerializeLegacy() ([]byte, error) {
+	var data bytes.Buffer // Stand-in for a network connection
+	enc := gob.NewEncoder(&data)
+
+	err := enc.Encode(record.Timestamp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.Encode(record.ToAddress)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.Encode(record.Value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.Encode(record.PubKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.Encode(record.PrevTxID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Bytes(), nil
+}
+
+// LegacyHash returns the SHA256 hash of the legacy gob encoding.
+func (record *Record) LegacyHash() ([]byte, error) {
+	data, err := record.SerializeLegacy()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hashBytes := sha256.Sum256(data)
+
+	return hashBytes[:], nil
+}
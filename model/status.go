@@ -0,0 +1,15 @@
+package model
+
+// Status of a transaction in a TransactionStatus
+const (
+	StatusPending   = "pending"   // Accepted, in the mempool
+	StatusConfirmed = "confirmed" // Saved to the ledger
+	StatusDropped   = "dropped"   // Accepted, then dropped from the mempool because it can not be saved
+)
+
+// TransactionStatus is whether a transaction is pending, confirmed or dropped.
+type TransactionStatus struct {
+	TxID   string `json:"txId"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"` // Why a dropped transaction was dropped
+}
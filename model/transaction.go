@@ -1,20 +1,53 @@
 package model
 
 import (
-	"bytes"
-	"crypto/sha256"
-	"encoding/gob"
+	"encoding/hex"
+	"strconv"
 	"time"
 )
 
-// Transaction struct
+// Transaction moves the value of outputs of previous transactions (the inputs) to new outputs.
+// The total value of the inputs minus the total value of the outputs is the fee of the transaction.
+// A transaction without inputs is a genesis transaction, it creates the value of its outputs, or a
+// fee transaction, which sends the fees of the transactions it collects to the operator.
 type Transaction struct {
-	Timestamp     time.Time `json:"timestamp"`
-	ToAddress     string    `json:"toAddress"`
-	Value         int64     `json:"value"`
-	PubKey        string    `json:"pubKey"`
-	PrevSignature string    `json:"prevSignature"`
-	Signature     string    `json:"signature"`
+	TxID      string    `json:"txId"`      // Hash of the unsigned content, see ID
+	Timestamp time.Time `json:"timestamp"` // Stored in UTC with nanosecond precision
+	Inputs    []Input   `json:"inputs"`
+	Outputs   []Output  `json:"outputs"`
+	Collects  []string  `json:"collects,omitempty"` // Fee transactions only: TxIDs of the transactions paying the fees
+
+	Acceptance *Acceptance `json:"acceptance,omitempty"` // Set by the server when it accepts the transaction
+
+	// Set by the repository if the timestamp was saved in seconds, before timestamps had nanosecond
+	// precision: the signature may not verify anymore
+	TimestampInSeconds bool `json:"-"`
+}
+
+// Outpoint identifies an output of a transaction.
+type Outpoint struct {
+	TxID  string `json:"txId"`
+	Index int    `json:"index"` // Position in the outputs of the transaction
+}
+
+// Input spends an output of a previous transaction. The signature is made with the key of the
+// address of the output, over the hash of the transaction (see Hash).
+type Input struct {
+	Outpoint  Outpoint `json:"outpoint"`
+	PubKey    string   `json:"pubKey"`
+	Signature string   `json:"signature"` // Witness only, it does not identify the transaction
+}
+
+// Output sends value to an address (public key).
+type Output struct {
+	ToAddress string `json:"toAddress"`
+	Value     int64  `json:"value"`
+}
+
+// UnspentOutput is an output which is not spent by any transaction yet.
+type UnspentOutput struct {
+	Outpoint
+	Output
 }
 
 // NewTransaction ?
@@ -23,53 +56,73 @@ func NewTransaction() *Transaction {
 	return t
 }
 
-// Serialize ?
-func (transaction *Transaction) Serialize() ([]byte, error) {
-	var data bytes.Buffer // Stand-in for a network connection
-	enc := gob.NewEncoder(&data)
-
-	err := enc.Encode(transaction.Timestamp)
-
+// ID returns the TxID of the transaction: the hex encoded hash of the canonical encoding. The signatures
+// are not part of the encoding, so every signature of the same payment has the same ID.
+func (transaction *Transaction) ID() (string, error) {
+	hash, err := transaction.Hash()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	err = enc.Encode(transaction.ToAddress)
+	return hex.EncodeToString(hash), nil
+}
 
-	if err != nil {
-		return nil, err
-	}
+// NormalizeTimestamp converts the timestamp to the way it is stored: in UTC, with nanosecond precision
+// and without a monotonic clock reading. The hash of the transaction does not change.
+func (transaction *Transaction) NormalizeTimestamp() {
+	transaction.Timestamp = transaction.Timestamp.UTC()
+}
 
-	err = enc.Encode(transaction.Value)
+// Outpoint returns the outpoint of the output at index.
+func (transaction *Transaction) Outpoint(index int) Outpoint {
+	return Outpoint{TxID: transaction.TxID, Index: index}
+}
 
-	if err != nil {
-		return nil, err
+// Output returns the output the outpoint refers to, or nil if the transaction does not have it.
+func (transaction *Transaction) Output(outpoint Outpoint) *Output {
+	if outpoint.TxID != transaction.TxID || outpoint.Index < 0 || outpoint.Index >= len(transaction.Outputs) {
+		return nil
 	}
 
-	err = enc.Encode(transaction.PubKey)
+	return &transaction.Outputs[outpoint.Index]
+}
 
-	if err != nil {
-		return nil, err
+// OutputValue returns the total value of the outputs.
+func (transaction *Transaction) OutputValue() int64 {
+	var value int64
+	for _, output := range transaction.Outputs {
+		value += output.Value
 	}
+	return value
+}
 
-	err = enc.Encode(transaction.PrevSignature)
+// IsFee returns true if the transaction is a fee transaction, created by the server when it saves
+// transactions paying fees.
+func (transaction *Transaction) IsFee() bool {
+	return len(transaction.Inputs) == 0 && len(transaction.Collects) > 0
+}
 
-	if err != nil {
-		return nil, err
+// HasOutputTo returns true if the transaction sends value to the address.
+func (transaction *Transaction) HasOutputTo(address string) bool {
+	for _, output := range transaction.Outputs {
+		if output.ToAddress == address {
+			return true
+		}
 	}
-
-	return data.Bytes(), nil
+	return false
 }
 
-// Hash ?
-func (transaction *Transaction) Hash() ([]byte, error) {
-	data, err := transaction.Serialize()
-
-	if err != nil {
-		return nil, err
+// HasInputFrom returns true if the transaction spends an output of the public key.
+func (transaction *Transaction) HasInputFrom(pubKey string) bool {
+	for _, input := range transaction.Inputs {
+		if input.PubKey == pubKey {
+			return true
+		}
 	}
+	return false
+}
 
-	hashBytes := sha256.Sum256(data)
-
-	return hashBytes[:], nil
+// String returns the outpoint as TxID:index.
+func (outpoint Outpoint) String() string {
+	return outpoint.TxID + ":" + strconv.Itoa(outpoint.Index)
 }
@@ -3,11 +3,12 @@ package model
 import (
 	"bytes"
 	"testing"
+	"time"
 )
 
 func TestSerialize(t *testing.T) {
-	t1 := Transaction{Value: 1, PubKey: "123"}
-	t2 := Transaction{Value: 1, PubKey: "123"}
+	t1 := Transaction{Outputs: []Output{{Value: 1, ToAddress: "123"}}}
+	t2 := Transaction{Outputs: []Output{{Value: 1, ToAddress: "123"}}}
 
 	bytes1, err1 := t1.Serialize()
 	bytes2, err2 := t2.Serialize()
@@ -18,8 +19,8 @@ func TestSerialize(t *testing.T) {
 }
 
 func TestHash(t *testing.T) {
-	t1 := Transaction{Value: 1, PubKey: "123"}
-	t2 := Transaction{Value: 1, PubKey: "123"}
+	t1 := Transaction{Outputs: []Output{{Value: 1, ToAddress: "123"}}}
+	t2 := Transaction{Outputs: []Output{{Value: 1, ToAddress: "123"}}}
 
 	h1, err1 := t1.Hash()
 	h2, err2 := t2.Hash()
@@ -28,3 +29,34 @@ func TestHash(t *testing.T) {
 		t.Error("Hash does not match:", h1, h2, err1, err2)
 	}
 }
+
+func TestLegacyHash(t *testing.T) {
+	t1 := Record{Value: 1, PubKey: "123"}
+	t2 := Record{Value: 1, PubKey: "123"}
+
+	h1, err1 := t1.LegacyHash()
+	h2, err2 := t2.LegacyHash()
+	h3, _ := t1.Hash()
+
+	if bytes.Compare(h1, h2) != 0 || bytes.Compare(h1, h3) == 0 || err1 != nil || err2 != nil {
+		t.Error("Legacy hash does not match:", h1, h2, h3, err1, err2)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	r := Record{TxID: "b", Timestamp: time.Now(), ToAddress: "x", Value: 10, PubKey: "y", PrevTxID: "a", Signature: "s"}
+	transaction := r.Transaction()
+
+	converted, ok := transaction.Record()
+
+	if !ok || *converted != r {
+		t.Error("Record does not match:", r, converted)
+	}
+
+	genesis := Record{TxID: "a", ToAddress: "y", Value: 10, PubKey: "y", PrevTxID: "GENESIS"}
+	transaction = genesis.Transaction()
+
+	if _, ok := transaction.Record(); len(transaction.Inputs) != 0 || transaction.Outputs[0].Value != 10 || ok {
+		t.Error("Genesis record does not match:", transaction)
+	}
+}
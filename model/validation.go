@@ -0,0 +1,33 @@
+package model
+
+// Status of a rule in a RuleResult
+const (
+	RulePassed  = "passed"
+	RuleFailed  = "failed"
+	RuleSkipped = "skipped" // A rule it requires did not pass
+)
+
+// RuleResult is the result of a rule checked for a transaction.
+type RuleResult struct {
+	Code    string `json:"code"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"` // Why the rule failed
+}
+
+// TransactionReport is the result of the validation of a transaction of a batch.
+type TransactionReport struct {
+	Index  int          `json:"index"` // Position of the transaction in the batch
+	TxID   string       `json:"txId"`
+	Valid  bool         `json:"valid"`
+	Rules  []RuleResult `json:"rules"`
+	Fee    int64        `json:"fee"`    // Total value of the inputs minus the outputs
+	Change int64        `json:"change"` // Total value of the outputs sent back to the owners of the inputs
+}
+
+// ValidationReport is the result of the validation of a batch of transactions without saving it.
+type ValidationReport struct {
+	Valid        bool                `json:"valid"`
+	Transactions []TransactionReport `json:"transactions"`
+	Fee          int64               `json:"fee"`    // Total fee of the batch, sent to the operator
+	Change       int64               `json:"change"` // Total change of the batch
+}
@@ -2,13 +2,20 @@ package model
 
 import (
 	"cryptocoin-server/util/ecdsa"
+	"time"
 )
 
 // Wallet struct containing private key, public key, and balance
 type Wallet struct {
-	PrivKey   string
-	PubKey    string
-	Balanance int64
+	PrivKey        string
+	PubKey         string
+	Balanance      int64
+	UnspentOutputs []UnspentOutput // Unspent outputs sent to the wallet, their total is the balance
+	UnspentCount   int
+	PendingBalance int64      // Balance including the pending transactions
+	PendingCount   int        // Number of pending transactions sent to or from the wallet
+	FirstActivity  *time.Time // Timestamp of the first transaction sent to or from the wallet
+	LastActivity   *time.Time // Timestamp of the last transaction sent to or from the wallet
 }
 
 // NewWallet creates a new wallet with a private and public key.
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// maxTarget is the easiest target, which every hash meets.
+var maxTarget = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// Retargeting changes the target at most by this factor.
+const maxRetargetFactor = 4
+
+// TargetFromBits returns the target of hashes which start with at least bits zero bits.
+func TargetFromBits(bits int) string {
+	return formatTarget(new(big.Int).Rsh(maxTarget, uint(bits)))
+}
+
+// ParseTarget parses a hex encoded target of 32 bytes, which must not be zero.
+func ParseTarget(target string) (*big.Int, error) {
+	data, err := hex.DecodeString(target)
+	if err != nil || len(data) != sha256.Size {
+		return nil, errors.New("Target must be 32 hex encoded bytes")
+	}
+
+	value := new(big.Int).SetBytes(data)
+	if value.Sign() == 0 {
+		return nil, errors.New("Target must not be zero")
+	}
+
+	return value, nil
+}
+
+// formatTarget returns the hex encoding of the target, 32 bytes with the leading zeros.
+func formatTarget(target *big.Int) string {
+	return fmt.Sprintf("%064x", target)
+}
+
+// Difficulty returns how many times harder the target is than the easiest target, 0 if it is not valid.
+func Difficulty(target string) float64 {
+	value, err := ParseTarget(target)
+	if err != nil {
+		return 0
+	}
+
+	difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(maxTarget), new(big.Float).SetInt(value)).Float64()
+	return difficulty
+}
+
+// Retarget returns the target which makes blocks take the expected time, from the target of blocks which
+// took the actual time. The target changes at most by a factor of 4 and is never easier than every hash.
+func Retarget(target string, actual time.Duration, expected time.Duration) (string, error) {
+	value, err := ParseTarget(target)
+	if err != nil {
+		return "", err
+	}
+
+	if expected <= 0 {
+		return "", errors.New("Expected time must be positive")
+	}
+
+	if actual < expected/maxRetargetFactor {
+		actual = expected / maxRetargetFactor
+	}
+	if actual > expected*maxRetargetFactor {
+		actual = expected * maxRetargetFactor
+	}
+
+	value.Mul(value, big.NewInt(int64(actual)))
+	value.Quo(value, big.NewInt(int64(expected)))
+
+	if value.Cmp(maxTarget) > 0 {
+		value.Set(maxTarget)
+	}
+	if value.Sign() == 0 {
+		value.SetInt64(1)
+	}
+
+	return formatTarget(value), nil
+}
+
+// FollowsTarget returns true if the target of the block may follow the target of previous: once a block
+// has a proof of work target, every following block has one, at most maxRetargetFactor times easier
+// (see Retarget).
+func (block *Block) FollowsTarget(previous *Block) bool {
+	if previous == nil || previous.Header.Target == "" {
+		return true
+	}
+
+	target, err := ParseTarget(block.Header.Target)
+	if err != nil {
+		return false
+	}
+
+	limit, err := ParseTarget(previous.Header.Target)
+	if err != nil {
+		return false
+	}
+
+	return target.Cmp(limit.Mul(limit, big.NewInt(maxRetargetFactor))) <= 0
+}
+
+// MeetsTarget returns true if the hash of the header, as a big endian number, is not greater than the
+// target of the header.
+func (header *BlockHeader) MeetsTarget() bool {
+	target, err := ParseTarget(header.Target)
+	if err != nil {
+		return false
+	}
+
+	hash := sha256.Sum256(header.Serialize())
+	return new(big.Int).SetBytes(hash[:]).Cmp(target) <= 0
+}
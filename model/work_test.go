@@ -0,0 +1,106 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTargetFromBits(t *testing.T) {
+	if target := TargetFromBits(0); target != strings.Repeat("f", 64) {
+		t.Error("Easiest target does not match:", target)
+	}
+
+	if target := TargetFromBits(12); target != "000"+strings.Repeat("f", 61) {
+		t.Error("Target does not match:", target)
+	}
+
+	if difficulty := Difficulty(TargetFromBits(4)); difficulty < 16 || difficulty > 16.01 {
+		t.Error("Difficulty does not match:", difficulty)
+	}
+
+	for _, target := range []string{"", "00", ZeroHash, strings.Repeat("x", 64)} {
+		if _, err := ParseTarget(target); err == nil {
+			t.Error("Invalid target parsed:", target)
+		}
+	}
+}
+
+func TestRetarget(t *testing.T) {
+	target := TargetFromBits(8)
+
+	for _, c := range []struct {
+		actual time.Duration
+		bits   int
+	}{
+		{time.Minute, 8},      // On time
+		{2 * time.Minute, 7},  // Twice as long, twice as easy
+		{time.Minute / 2, 9},  // Twice as fast, twice as hard
+		{time.Hour, 6},        // At most 4 times easier
+		{time.Nanosecond, 10}, // At most 4 times harder
+	} {
+		result, err := Retarget(target, c.actual, time.Minute)
+		if difficulty := Difficulty(result) / Difficulty(TargetFromBits(c.bits)); difficulty < 0.99 || difficulty > 1.01 || err != nil {
+			t.Error("Target does not match:", c.actual, result, err)
+		}
+	}
+
+	if result, _ := Retarget(TargetFromBits(1), time.Hour, time.Minute); result != TargetFromBits(0) {
+		t.Error("Target is easier than every hash:", result)
+	}
+}
+
+func TestFollowsTarget(t *testing.T) {
+	first := NewBlock(nil, []string{"a"})
+	second := NewBlock(first, []string{"b"})
+	second.Header.Target = TargetFromBits(8)
+
+	// Proof of work may start at any block
+	if !second.FollowsTarget(first) || !first.FollowsTarget(nil) {
+		t.Error("Target of the first block with proof of work does not follow")
+	}
+
+	easiest, _ := Retarget(second.Header.Target, time.Hour, time.Minute)
+
+	for _, c := range []struct {
+		target  string
+		follows bool
+	}{
+		{TargetFromBits(8), true},
+		{TargetFromBits(12), true}, // Harder
+		{easiest, true},
+		{TargetFromBits(5), false}, // 8 times easier
+		{"", false},
+		{"x", false},
+	} {
+		third := NewBlock(second, []string{"c"})
+		third.Header.Target = c.target
+		if third.FollowsTarget(second) != c.follows {
+			t.Error("Target does not match:", c.target, c.follows)
+		}
+	}
+}
+
+func TestMeetsTarget(t *testing.T) {
+	block := NewBlock(nil, []string{"a"})
+	v1 := block.Header.Hash()
+
+	// Every hash meets the easiest target, the target is part of the hash
+	block.Header.Target = TargetFromBits(0)
+	if !block.Header.MeetsTarget() || block.Header.Hash() == v1 || block.Header.Serialize()[0] != BlockVersionWork {
+		t.Error("Header with a target does not match:", block.Header)
+	}
+
+	nonce := block.Header.Hash()
+	block.Header.Nonce = 1
+	if block.Header.Hash() == nonce {
+		t.Error("Nonce is not part of the hash")
+	}
+
+	// No hash meets the hardest target in practice
+	block.Header.Target = strings.Repeat("0", 63) + "1"
+	block.Hash = block.Header.Hash()
+	if block.Header.MeetsTarget() || block.Valid() {
+		t.Error("Header meets the hardest target:", block.Header)
+	}
+}
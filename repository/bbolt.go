@@ -0,0 +1,789 @@
+package repository
+
+import (
+	"bytes"
+	"cryptocoin-server/model"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+var (
+	transactionsBucket = []byte("transactions") // TxID -> transaction
+	timestampsBucket   = []byte("timestamps")   // timestamp + TxID -> nothing, for ordered listing
+	spentByBucket      = []byte("spentBy")      // outpoint (TxID:index) -> TxID of the transaction spending it
+	addressesBucket    = []byte("addresses")    // toAddress -> bucket of TxIDs sent to it
+	metaBucket         = []byte("meta")         // format -> version of the stored transactions, sequence -> last reserved sequence number
+	blocksBucket       = []byte("blocks")       // height -> block
+	blockHashesBucket  = []byte("blockHashes")  // hash -> height of the block
+	blockTxIDsBucket   = []byte("blockTxIds")   // TxID -> height of the block containing it
+	logBucket          = []byte("log")          // index -> TxID, in the order the transactions were saved
+	logIndexBucket     = []byte("logIndex")     // TxID -> index in the log
+	quarantineBucket   = []byte("quarantine")   // TxID of a quarantined transaction -> nothing
+)
+
+// bboltFormat is the version of the stored transactions. Version 1 stored records (see model.Record).
+const bboltFormat = 2
+
+// BBoltRepository stores the ledger in a single bbolt database file.
+type BBoltRepository struct {
+	db *bbolt.DB
+}
+
+// NewBBoltRepository opens (or creates) the bbolt database file at path. A ledger of records is
+// converted to transactions.
+func NewBBoltRepository(path string) (*BBoltRepository, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{transactionsBucket, timestampsBucket, spentByBucket, addressesBucket, metaBucket, blocksBucket, blockHashesBucket, blockTxIDsBucket, logBucket, logIndexBucket, quarantineBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		return migrateLog(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	r := new(BBoltRepository)
+	r.db = db
+	return r, nil
+}
+
+// legacyRecord is a record saved before records had a TxID. It is keyed by its signature, which becomes
+// its TxID so references to it keep working, the same as in the graph.
+type legacyRecord struct {
+	model.Record
+	PrevSignature string `json:"prevSignature"`
+}
+
+// migrate converts the records of a ledger of format 1 to transactions. The spentBy index of records
+// had a bucket of the records spending each record, it is replaced by an index of the spent outputs.
+// The keys of the other indexes are the same for records saved before they had a TxID.
+func migrate(tx *bbolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	if format := meta.Get([]byte("format")); format != nil && format[0] == bboltFormat {
+		return nil
+	}
+
+	transactions := tx.Bucket(transactionsBucket)
+	var converted []model.Transaction
+
+	err := transactions.ForEach(func(k, v []byte) error {
+		var record legacyRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		if record.TxID == "" {
+			record.TxID = string(k)
+			record.PrevTxID = record.PrevSignature
+		}
+		converted = append(converted, record.Transaction())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteBucket(spentByBucket); err != nil {
+		return err
+	}
+	if _, err := tx.CreateBucket(spentByBucket); err != nil {
+		return err
+	}
+
+	for _, t := range converted {
+		data, err := json.Marshal(t)
+		if err == nil {
+			err = transactions.Put([]byte(t.TxID), data)
+		}
+		if err == nil {
+			err = saveSpends(tx, &t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return meta.Put([]byte("format"), []byte{bboltFormat})
+}
+
+// migrateLog appends the transactions saved before the log, ordered by timestamp and TxID.
+func migrateLog(tx *bbolt.Tx) error {
+	if k, _ := tx.Bucket(logBucket).Cursor().First(); k != nil {
+		return nil
+	}
+
+	c := tx.Bucket(timestampsBucket).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if err := appendLog(tx, string(k[8:])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendLog appends the transaction to the log, unless it is in the log already.
+func appendLog(tx *bbolt.Tx, txID string) error {
+	logIndex := tx.Bucket(logIndexBucket)
+	if logIndex.Get([]byte(txID)) != nil {
+		return nil
+	}
+
+	index := int64(0)
+	if k, _ := tx.Bucket(logBucket).Cursor().Last(); k != nil {
+		index = int64(binary.BigEndian.Uint64(k)) + 1
+	}
+
+	if err := tx.Bucket(logBucket).Put(heightKey(index), []byte(txID)); err != nil {
+		return err
+	}
+
+	return logIndex.Put([]byte(txID), heightKey(index))
+}
+
+// Ping checks that the database file is open.
+func (r *BBoltRepository) Ping() error {
+	return r.db.View(func(tx *bbolt.Tx) error { return nil })
+}
+
+// Close closes the database file.
+func (r *BBoltRepository) Close() error {
+	return r.db.Close()
+}
+
+// SaveTransactions saves all the transactions to the ledger in one database transaction.
+func (r *BBoltRepository) SaveTransactions(transactions []model.Transaction) error {
+	return commitError(r.db.Update(func(tx *bbolt.Tx) error {
+		return saveBatch(tx, transactions)
+	}))
+}
+
+// SaveBlock saves the block and its transactions in one database transaction.
+func (r *BBoltRepository) SaveBlock(block *model.Block, transactions []model.Transaction) error {
+	if !block.Valid() {
+		return &CommitError{Err: ErrInvalidBlock}
+	}
+
+	return commitError(r.db.Update(func(tx *bbolt.Tx) error {
+		_, data := tx.Bucket(blocksBucket).Cursor().Last()
+		last, err := blockFromData(data)
+		if err != nil {
+			return err
+		}
+
+		if !block.Follows(last) {
+			return &CommitError{Err: ErrBlockConflict}
+		}
+
+		if !block.FollowsTarget(last) {
+			return &CommitError{Err: ErrInvalidBlock}
+		}
+
+		if err := saveBatch(tx, transactions); err != nil {
+			return err
+		}
+
+		data, err = json.Marshal(block)
+		if err != nil {
+			return err
+		}
+
+		key := heightKey(block.Header.Height)
+		if err := tx.Bucket(blocksBucket).Put(key, data); err != nil {
+			return err
+		}
+
+		for _, txID := range block.TxIDs {
+			if err := tx.Bucket(blockTxIDsBucket).Put([]byte(txID), key); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(blockHashesBucket).Put([]byte(block.Hash), key)
+	}))
+}
+
+// commitError returns the error of a database transaction saving transactions as a *CommitError.
+func commitError(err error) error {
+	if err != nil {
+		if _, ok := err.(*CommitError); !ok {
+			err = &CommitError{Err: err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// saveBatch saves the transactions in the database transaction.
+func saveBatch(tx *bbolt.Tx, transactions []model.Transaction) error {
+	// bbolt has a single writer, so nothing can save or spend after these checks
+	get := getter(tx)
+	batch := make(map[string]*model.Transaction)
+	for i, t := range transactions {
+		if tx.Bucket(transactionsBucket).Get([]byte(t.TxID)) != nil || batch[t.TxID] != nil {
+			return &CommitError{TxID: t.TxID, Err: ErrTransactionExists}
+		}
+		batch[t.TxID] = &transactions[i]
+	}
+
+	spent := make(map[model.Outpoint]bool)
+	for _, t := range transactions {
+		for _, input := range t.Inputs {
+			if tx.Bucket(spentByBucket).Get(outpointKey(input.Outpoint)) != nil || spent[input.Outpoint] {
+				return &CommitError{TxID: t.TxID, Err: ErrAlreadySpent}
+			}
+			spent[input.Outpoint] = true
+
+			pt := batch[input.Outpoint.TxID]
+			if pt == nil {
+				var err error
+				if pt, err = get(input.Outpoint.TxID); err != nil {
+					return &CommitError{TxID: t.TxID, Err: err}
+				}
+			}
+			if pt == nil || pt.Output(input.Outpoint) == nil {
+				return &CommitError{TxID: t.TxID, Err: ErrMissingOutput}
+			}
+		}
+	}
+
+	for _, t := range transactions {
+		t.NormalizeTimestamp()
+		if err := saveTransaction(tx, &t); err != nil {
+			return &CommitError{TxID: t.TxID, Err: err}
+		}
+		if err := appendLog(tx, t.TxID); err != nil {
+			return &CommitError{TxID: t.TxID, Err: err}
+		}
+	}
+
+	for _, t := range transactions {
+		if err := saveSpends(tx, &t); err != nil {
+			return &CommitError{TxID: t.TxID, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// saveTransaction saves the transaction and its address index.
+func saveTransaction(tx *bbolt.Tx, t *model.Transaction) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	key := []byte(t.TxID)
+
+	if err := tx.Bucket(transactionsBucket).Put(key, data); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(timestampsBucket).Put(timestampKey(NewCursor(t)), []byte{}); err != nil {
+		return err
+	}
+
+	for _, output := range t.Outputs {
+		if output.ToAddress == "" {
+			continue
+		}
+
+		addresses, err := tx.Bucket(addressesBucket).CreateBucketIfNotExists([]byte(output.ToAddress))
+		if err == nil {
+			err = addresses.Put(key, []byte{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveSpends marks the outputs spent by the inputs of the transaction. Same as the graph, an output is
+// only spent when the previous transaction exists: a converted record may spend a record which was
+// never saved.
+func saveSpends(tx *bbolt.Tx, t *model.Transaction) error {
+	for _, input := range t.Inputs {
+		if tx.Bucket(transactionsBucket).Get([]byte(input.Outpoint.TxID)) == nil {
+			continue
+		}
+
+		if err := tx.Bucket(spentByBucket).Put(outpointKey(input.Outpoint), []byte(t.TxID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetTransactions returns the transactions selected by the filter, using the timestamps index.
+func (r *BBoltRepository) GetTransactions(filter Filter) ([]model.Transaction, error) {
+	var results []model.Transaction
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		if filter.ToAddress != "" {
+			var err error
+			results, err = getTransactionsTo(tx, filter)
+			return err
+		}
+
+		get := getter(tx)
+		c := tx.Bucket(timestampsBucket).Cursor()
+
+		// Start at the cursor or at the start of the time range
+		var k []byte
+		var start *Cursor
+		if filter.After != nil {
+			start = filter.After
+		} else if !filter.Descending && !filter.From.IsZero() {
+			start = &Cursor{Timestamp: filter.From}
+		} else if filter.Descending && !filter.To.IsZero() {
+			start = &Cursor{Timestamp: filter.To}
+		}
+
+		switch {
+		case start == nil && filter.Descending:
+			k, _ = c.Last()
+		case start == nil:
+			k, _ = c.First()
+		case filter.Descending:
+			// Seek returns the first key at or after the start, the walk begins before it
+			k, _ = c.Seek(timestampKey(start))
+			if k == nil {
+				k, _ = c.Last()
+			}
+			for k != nil && bytes.Compare(k, timestampKey(start)) >= 0 {
+				k, _ = c.Prev()
+			}
+		default:
+			k, _ = c.Seek(timestampKey(start))
+		}
+
+		for ; k != nil && len(results) < filter.Limit; k = step(c, filter.Descending) {
+			t, err := get(string(k[8:]))
+			if err != nil {
+				return err
+			}
+
+			// The rest of the index is outside of the time range
+			if !filter.Descending && !filter.To.IsZero() && !t.Timestamp.Before(filter.To) {
+				break
+			}
+			if filter.Descending && !filter.From.IsZero() && t.Timestamp.Before(filter.From) {
+				break
+			}
+
+			if filter.afterCursor(t) && filter.matches(t, isSpent(tx, t)) {
+				results = append(results, *t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// getTransactionsTo returns the transactions of the filter from the address index, which holds fewer
+// transactions than the timestamps index for any address.
+func getTransactionsTo(tx *bbolt.Tx, filter Filter) ([]model.Transaction, error) {
+	var results []model.Transaction
+
+	addresses := tx.Bucket(addressesBucket).Bucket([]byte(filter.ToAddress))
+	if addresses == nil {
+		return nil, nil
+	}
+
+	get := getter(tx)
+	err := addresses.ForEach(func(k, v []byte) error {
+		t, err := get(string(k))
+		if err != nil || t == nil {
+			return err
+		}
+
+		if filter.afterCursor(t) && filter.matches(t, isSpent(tx, t)) {
+			results = append(results, *t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filter.sort(results)
+	if len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+
+	return results, nil
+}
+
+// step moves the cursor to the next key in the order of the walk.
+func step(c *bbolt.Cursor, descending bool) []byte {
+	var k []byte
+	if descending {
+		k, _ = c.Prev()
+	} else {
+		k, _ = c.Next()
+	}
+	return k
+}
+
+// GetTransaction returns transaction by TxID.
+func (r *BBoltRepository) GetTransaction(txID string) (*model.Transaction, error) {
+	var transaction *model.Transaction
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		transaction, err = getter(tx)(txID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// LookupOutputs returns the transactions of all the outputs in one call.
+func (r *BBoltRepository) LookupOutputs(outpoints []model.Outpoint) (*Lookup, error) {
+	lookup := newLookup()
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		get := getter(tx)
+		spentBy := tx.Bucket(spentByBucket)
+		quarantine := tx.Bucket(quarantineBucket)
+
+		for _, outpoint := range uniqueOutpoints(outpoints) {
+			t, err := get(outpoint.TxID)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case t == nil || t.Output(outpoint) == nil || quarantine.Get([]byte(outpoint.TxID)) != nil:
+				lookup.Missing = append(lookup.Missing, outpoint)
+			case spentBy.Get(outpointKey(outpoint)) != nil:
+				lookup.Spent = append(lookup.Spent, outpoint)
+			default:
+				lookup.Unspent[outpoint] = t
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lookup, nil
+}
+
+// GetHistory returns the transaction and the previous transactions of its inputs, recursively.
+func (r *BBoltRepository) GetHistory(txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	var results []model.Transaction
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		results, err = history(getter(tx), txID, depth, offset, limit)
+		return err
+	})
+
+	return results, err
+}
+
+// GetDescendants returns the transaction and the transactions spending its outputs, recursively.
+func (r *BBoltRepository) GetDescendants(txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	var results []model.Transaction
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		get := getter(tx)
+		spentBy := tx.Bucket(spentByBucket)
+
+		spending := func(level []model.Transaction) ([]model.Transaction, error) {
+			var results []model.Transaction
+			for _, t := range level {
+				for i := range t.Outputs {
+					s := spentBy.Get(outpointKey(t.Outpoint(i)))
+					if s == nil {
+						continue
+					}
+
+					c, err := get(string(s))
+					if err != nil {
+						return nil, err
+					}
+					if c != nil {
+						results = append(results, *c)
+					}
+				}
+			}
+			return results, nil
+		}
+
+		var err error
+		results, err = walk(get, spending, txID, depth, offset, limit)
+		return err
+	})
+
+	return results, err
+}
+
+// getter returns a function which reads transactions in the database transaction.
+func getter(tx *bbolt.Tx) func(txID string) (*model.Transaction, error) {
+	transactions := tx.Bucket(transactionsBucket)
+
+	return func(txID string) (*model.Transaction, error) {
+		data := transactions.Get([]byte(txID))
+		if data == nil {
+			return nil, nil
+		}
+
+		t := new(model.Transaction)
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+}
+
+// isSpent returns true if all the outputs of the transaction are spent.
+func isSpent(tx *bbolt.Tx, t *model.Transaction) bool {
+	spentBy := tx.Bucket(spentByBucket)
+
+	for i := range t.Outputs {
+		if spentBy.Get(outpointKey(t.Outpoint(i))) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// GetBlocks returns the blocks from the height, using the order of the height keys.
+func (r *BBoltRepository) GetBlocks(from int64, limit int, descending bool) ([]model.Block, error) {
+	var results []model.Block
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(blocksBucket).Cursor()
+
+		var k, v []byte
+		switch {
+		case descending && from < 0:
+			k, v = c.Last()
+		case descending:
+			// Seek returns the next key if the height does not exist
+			if k, v = c.Seek(heightKey(from)); k == nil || !bytes.Equal(k, heightKey(from)) {
+				k, v = c.Prev()
+			}
+		case from < 0:
+			k, v = c.First()
+		default:
+			k, v = c.Seek(heightKey(from))
+		}
+
+		for ; k != nil && len(results) < limit; k, v = stepBlock(c, descending) {
+			b, err := blockFromData(v)
+			if err != nil {
+				return err
+			}
+			results = append(results, *b)
+		}
+
+		return nil
+	})
+
+	return results, err
+}
+
+// stepBlock moves the cursor to the next block in the order.
+func stepBlock(c *bbolt.Cursor, descending bool) ([]byte, []byte) {
+	if descending {
+		return c.Prev()
+	}
+	return c.Next()
+}
+
+// GetBlock returns the block at the height.
+func (r *BBoltRepository) GetBlock(height int64) (*model.Block, error) {
+	var result *model.Block
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		result, err = blockFromData(tx.Bucket(blocksBucket).Get(heightKey(height)))
+		return err
+	})
+
+	return result, err
+}
+
+// GetBlockByHash returns the block by hash, using the hashes index.
+func (r *BBoltRepository) GetBlockByHash(hash string) (*model.Block, error) {
+	return r.getIndexedBlock(blockHashesBucket, hash)
+}
+
+// GetBlockByTxID returns the block containing the transaction, using the TxIDs index.
+func (r *BBoltRepository) GetBlockByTxID(txID string) (*model.Block, error) {
+	return r.getIndexedBlock(blockTxIDsBucket, txID)
+}
+
+// getIndexedBlock returns the block of the key in an index of block heights.
+func (r *BBoltRepository) getIndexedBlock(index []byte, key string) (*model.Block, error) {
+	var result *model.Block
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		height := tx.Bucket(index).Get([]byte(key))
+		if height == nil {
+			return nil
+		}
+
+		var err error
+		result, err = blockFromData(tx.Bucket(blocksBucket).Get(height))
+		return err
+	})
+
+	return result, err
+}
+
+// LastBlock returns the block with the greatest height.
+func (r *BBoltRepository) LastBlock() (*model.Block, error) {
+	var result *model.Block
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		_, data := tx.Bucket(blocksBucket).Cursor().Last()
+		result, err = blockFromData(data)
+		return err
+	})
+
+	return result, err
+}
+
+// LogSize returns the number of transactions in the log.
+func (r *BBoltRepository) LogSize() (int64, error) {
+	var size int64
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		if k, _ := tx.Bucket(logBucket).Cursor().Last(); k != nil {
+			size = int64(binary.BigEndian.Uint64(k)) + 1
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// ReserveSequence reserves count more sequence numbers and returns the last one.
+func (r *BBoltRepository) ReserveSequence(count int64) (int64, error) {
+	var sequence int64
+
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if data := meta.Get([]byte("sequence")); data != nil {
+			sequence = int64(binary.BigEndian.Uint64(data))
+		}
+
+		sequence += count
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(sequence))
+		return meta.Put([]byte("sequence"), data)
+	})
+
+	return sequence, err
+}
+
+// GetLogEntries returns the TxIDs of the log from start to end.
+func (r *BBoltRepository) GetLogEntries(start int64, end int64) ([]string, error) {
+	var results []string
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		if start < 0 {
+			return nil
+		}
+
+		c := tx.Bucket(logBucket).Cursor()
+		for k, v := c.Seek(heightKey(start)); k != nil && int64(binary.BigEndian.Uint64(k)) < end; k, v = c.Next() {
+			results = append(results, string(v))
+		}
+		return nil
+	})
+
+	return results, err
+}
+
+// GetLogIndex returns the index of the transaction in the log.
+func (r *BBoltRepository) GetLogIndex(txID string) (int64, error) {
+	index := int64(-1)
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		if k := tx.Bucket(logIndexBucket).Get([]byte(txID)); k != nil {
+			index = int64(binary.BigEndian.Uint64(k))
+		}
+		return nil
+	})
+
+	return index, err
+}
+
+// Quarantine marks the transactions as quarantined.
+func (r *BBoltRepository) Quarantine(txIDs []string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		quarantine := tx.Bucket(quarantineBucket)
+		for _, txID := range txIDs {
+			if err := quarantine.Put([]byte(txID), []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// blockFromData decodes a stored block, nil if there is no data.
+func blockFromData(data []byte) (*model.Block, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	b := new(model.Block)
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// heightKey returns the key of the block at the height, or of the log entry at the index, big endian so
+// the keys are ordered.
+func heightKey(height int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(height))
+	return key
+}
+
+// outpointKey returns the key of the spentBy index.
+func outpointKey(outpoint model.Outpoint) []byte {
+	return []byte(outpoint.String())
+}
+
+// timestampKey returns the key of the timestamps index: the timestamp as 8 bytes which sort in
+// time order (the sign bit is flipped), followed by the TxID.
+func timestampKey(c *Cursor) []byte {
+	b := make([]byte, 8, 8+len(c.TxID))
+	binary.BigEndian.PutUint64(b, uint64(c.Timestamp.UnixNano())^(1<<63))
+	return append(b, c.TxID...)
+}
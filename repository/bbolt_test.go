@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"cryptocoin-server/model"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+func newTestBBoltRepository(t *testing.T) *BBoltRepository {
+	r, err := NewBBoltRepository(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatal("NewBBoltRepository failed:", err)
+	}
+
+	t.Cleanup(func() { r.Close() })
+
+	return r
+}
+
+func TestBBoltSaveTransaction(t *testing.T) {
+	testSaveTransaction(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltGetTransactions(t *testing.T) {
+	testGetTransactions(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltGetTransactionIsCopy(t *testing.T) {
+	testGetTransactionIsCopy(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.db")
+
+	r, _ := NewBBoltRepository(path)
+	testSaveTransaction(t, r)
+	r.Close()
+
+	r, err := NewBBoltRepository(path)
+	if err != nil {
+		t.Fatal("NewBBoltRepository failed:", err)
+	}
+	defer r.Close()
+
+	if result, err := r.GetTransaction("b"); result == nil || result.Outputs[0].ToAddress != "y" || err != nil {
+		t.Error("Transaction not persisted:", result, err)
+	}
+
+	if lookup, err := r.LookupOutputs([]model.Outpoint{outpoint("a", 0)}); len(lookup.Spent) != 1 || err != nil {
+		t.Error("Spent output not persisted:", lookup, err)
+	}
+}
+
+func TestBBoltSaveTransactionsBatch(t *testing.T) {
+	testSaveTransactionsBatch(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltSaveTransactionsRollback(t *testing.T) {
+	r := newTestBBoltRepository(t)
+
+	// bbolt does not accept an empty key
+	err := r.SaveTransactions([]model.Transaction{spend("a", time.Now(), nil, 10), spend("", time.Now(), nil, 10)})
+
+	if _, ok := err.(*CommitError); !ok {
+		t.Error("CommitError not returned:", err)
+	}
+
+	if result, err := r.GetTransaction("a"); result != nil || err != nil {
+		t.Error("Batch not rolled back:", result, err)
+	}
+}
+
+func TestBBoltSaveTransactionsDoubleSpend(t *testing.T) {
+	testSaveTransactionsDoubleSpend(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltSaveTransactionsRejected(t *testing.T) {
+	testSaveTransactionsRejected(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltLookupOutputs(t *testing.T) {
+	testLookupOutputs(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltGetHistory(t *testing.T) {
+	testGetHistory(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltGetDescendants(t *testing.T) {
+	testGetDescendants(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltGetTransactionsFilter(t *testing.T) {
+	testGetTransactionsFilter(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltTimestampRoundTrip(t *testing.T) {
+	testTimestampRoundTrip(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltSaveBlock(t *testing.T) {
+	testSaveBlock(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltSaveBlockTarget(t *testing.T) {
+	testSaveBlockTarget(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltGetBlocks(t *testing.T) {
+	testGetBlocks(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltLog(t *testing.T) {
+	testLog(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltQuarantine(t *testing.T) {
+	testQuarantine(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltAcceptance(t *testing.T) {
+	testAcceptance(t, newTestBBoltRepository(t))
+}
+
+func TestBBoltReserveSequenceReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.db")
+
+	r, _ := NewBBoltRepository(path)
+	r.ReserveSequence(10)
+	r.Close()
+
+	r, err := NewBBoltRepository(path)
+	if err != nil {
+		t.Fatal("NewBBoltRepository failed:", err)
+	}
+	defer r.Close()
+
+	if last, err := r.ReserveSequence(10); last != 20 || err != nil {
+		t.Error("Reserved sequence numbers were not kept:", last, err)
+	}
+}
+
+func TestBBoltMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.db")
+
+	// A ledger of records, as saved before transactions had inputs and outputs
+	db, _ := bbolt.Open(path, 0600, nil)
+	db.Update(func(tx *bbolt.Tx) error {
+		transactions, _ := tx.CreateBucket(transactionsBucket)
+		timestamps, _ := tx.CreateBucket(timestampsBucket)
+		spentBy, _ := tx.CreateBucket(spentByBucket)
+
+		for _, record := range []model.Record{
+			{TxID: "a", ToAddress: "x", Value: 10, PubKey: "x", PrevTxID: "GENESIS"},
+			{TxID: "b", ToAddress: "y", Value: 10, PubKey: "x", PrevTxID: "a", Signature: "s"},
+		} {
+			data, _ := json.Marshal(record)
+			transactions.Put([]byte(record.TxID), data)
+			timestamps.Put(timestampKey(&Cursor{TxID: record.TxID}), []byte{})
+		}
+
+		spent, _ := spentBy.CreateBucket([]byte("a"))
+		return spent.Put([]byte("b"), []byte{})
+	})
+	db.Close()
+
+	r, err := NewBBoltRepository(path)
+	if err != nil {
+		t.Fatal("NewBBoltRepository failed:", err)
+	}
+	defer r.Close()
+
+	b, err := r.GetTransaction("b")
+	if b == nil || len(b.Inputs) != 1 || b.Inputs[0].Outpoint != outpoint("a", 0) || b.Inputs[0].Signature != "s" || b.OutputValue() != 10 || err != nil {
+		t.Error("Record not converted:", b, err)
+	}
+
+	lookup, err := r.LookupOutputs([]model.Outpoint{outpoint("a", 0), outpoint("b", 0)})
+	if len(lookup.Spent) != 1 || lookup.Spent[0] != outpoint("a", 0) || len(lookup.Unspent) != 1 || err != nil {
+		t.Error("Spent outputs not converted:", lookup, err)
+	}
+
+	if entries, err := r.GetLogEntries(0, 10); strings.Join(entries, "") != "ab" || err != nil {
+		t.Error("Records not appended to the log:", entries, err)
+	}
+}
+
+func TestBBoltMigrateSignatureKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.db")
+
+	// A ledger of records, as saved before they had a TxID and were keyed by their signature
+	db, _ := bbolt.Open(path, 0600, nil)
+	db.Update(func(tx *bbolt.Tx) error {
+		transactions, _ := tx.CreateBucket(transactionsBucket)
+		timestamps, _ := tx.CreateBucket(timestampsBucket)
+		addresses, _ := tx.CreateBucket(addressesBucket)
+
+		for signature, record := range map[string]string{
+			"sa": `{"timestamp": "2018-05-01T12:00:00Z", "toAddress": "x", "value": 10, "pubKey": "x", "prevSignature": "GENESIS", "signature": "sa"}`,
+			"sb": `{"timestamp": "2018-05-01T12:00:01Z", "toAddress": "y", "value": 10, "pubKey": "x", "prevSignature": "sa", "signature": "sb"}`,
+		} {
+			transactions.Put([]byte(signature), []byte(record))
+			timestamps.Put(timestampKey(&Cursor{TxID: signature}), []byte{})
+		}
+
+		address, _ := addresses.CreateBucket([]byte("y"))
+		return address.Put([]byte("sb"), []byte{})
+	})
+	db.Close()
+
+	r, err := NewBBoltRepository(path)
+	if err != nil {
+		t.Fatal("NewBBoltRepository failed:", err)
+	}
+	defer r.Close()
+
+	b, err := r.GetTransaction("sb")
+	if b == nil || b.TxID != "sb" || len(b.Inputs) != 1 || b.Inputs[0].Outpoint != outpoint("sa", 0) || b.Inputs[0].Signature != "sb" || err != nil {
+		t.Error("Record not converted:", b, err)
+	}
+
+	lookup, err := r.LookupOutputs([]model.Outpoint{outpoint("sa", 0), outpoint("sb", 0)})
+	if len(lookup.Spent) != 1 || lookup.Spent[0] != outpoint("sa", 0) || len(lookup.Unspent) != 1 || err != nil {
+		t.Error("Spent outputs not converted:", lookup, err)
+	}
+
+	if results, err := r.GetTransactions(Filter{ToAddress: "y", Limit: 10}); len(results) != 1 || results[0].TxID != "sb" || err != nil {
+		t.Error("Address index not kept:", results, err)
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"cryptocoin-server/model"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter selects and orders the transactions returned by GetTransactions. Empty fields do not filter.
+type Filter struct {
+	ToAddress  string    // Transactions with an output to the address
+	PubKey     string    // Transactions with an input of the public key
+	From       time.Time // Transactions at or after the time
+	To         time.Time // Transactions before the time
+	MinValue   *int64    // Minimum total value of the outputs
+	MaxValue   *int64    // Maximum total value of the outputs
+	Spent      *bool     // Only transactions with all (true) or not all (false) outputs spent
+	After      *Cursor   // Transactions after the cursor in the order of the filter
+	Descending bool      // Order by timestamp and TxID, latest first
+	Limit      int
+}
+
+// Cursor is the position of a transaction in the order of timestamp and TxID.
+type Cursor struct {
+	Timestamp time.Time
+	TxID      string
+}
+
+// NewCursor returns the cursor of the transaction.
+func NewCursor(t *model.Transaction) *Cursor {
+	return &Cursor{Timestamp: t.Timestamp, TxID: t.TxID}
+}
+
+// String encodes the cursor as an opaque URL safe string.
+func (c *Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.Timestamp.UnixNano(), 10) + ":" + c.TxID))
+}
+
+// ParseCursor decodes a cursor encoded by Cursor.String.
+func ParseCursor(cursor string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	return &Cursor{Timestamp: time.Unix(0, nanos), TxID: parts[1]}, nil
+}
+
+// matches returns true if the transaction passes the filter. spent is true if all the outputs of the
+// transaction are spent. The cursor is not checked.
+func (f *Filter) matches(t *model.Transaction, spent bool) bool {
+	switch {
+	case f.ToAddress != "" && !t.HasOutputTo(f.ToAddress):
+		return false
+	case f.PubKey != "" && !t.HasInputFrom(f.PubKey):
+		return false
+	case !f.From.IsZero() && t.Timestamp.Before(f.From):
+		return false
+	case !f.To.IsZero() && !t.Timestamp.Before(f.To):
+		return false
+	case f.MinValue != nil && t.OutputValue() < *f.MinValue:
+		return false
+	case f.MaxValue != nil && t.OutputValue() > *f.MaxValue:
+		return false
+	case f.Spent != nil && spent != *f.Spent:
+		return false
+	}
+
+	return true
+}
+
+// afterCursor returns true if the transaction comes after the cursor in the order of the filter.
+func (f *Filter) afterCursor(t *model.Transaction) bool {
+	if f.After == nil {
+		return true
+	}
+
+	c := Cursor{Timestamp: t.Timestamp, TxID: t.TxID}
+	if f.Descending {
+		return less(&c, f.After)
+	}
+
+	return less(f.After, &c)
+}
+
+// sort orders the transactions in the order of the filter.
+func (f *Filter) sort(transactions []model.Transaction) {
+	sort.Slice(transactions, func(i, j int) bool {
+		a := Cursor{Timestamp: transactions[i].Timestamp, TxID: transactions[i].TxID}
+		b := Cursor{Timestamp: transactions[j].Timestamp, TxID: transactions[j].TxID}
+
+		if f.Descending {
+			return less(&b, &a)
+		}
+		return less(&a, &b)
+	})
+}
+
+// less returns true if a comes before b in the order of timestamp and TxID.
+func less(a *Cursor, b *Cursor) bool {
+	if a.Timestamp.Equal(b.Timestamp) {
+		return a.TxID < b.TxID
+	}
+
+	return a.Timestamp.Before(b.Timestamp)
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{Timestamp: time.Unix(1500000000, 123456789), TxID: "a/b+c=="}
+
+	parsed, err := ParseCursor(c.String())
+
+	if parsed == nil || !parsed.Timestamp.Equal(c.Timestamp) || parsed.TxID != c.TxID || err != nil {
+		t.Error("Cursors do not match:", c, parsed, err)
+	}
+
+	for _, invalid := range []string{"%%%", "YWJj", "eDph"} {
+		if _, err := ParseCursor(invalid); err == nil {
+			t.Error("Invalid cursor accepted:", invalid)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"cryptocoin-server/model"
+	"sync"
+)
+
+// MemoryRepository keeps the ledger in memory. It is intended for tests and development.
+type MemoryRepository struct {
+	mutex        sync.RWMutex
+	transactions map[string]model.Transaction
+	spentBy      map[model.Outpoint]string // Spent outputs -> TxID of the transaction spending it
+	blocks       []model.Block             // Blocks by height
+	blockHashes  map[string]int64          // Hash -> height of the block
+	blockTxIDs   map[string]int64          // TxID -> height of the block containing it
+	log          []string                  // TxIDs in the order they were saved
+	logIndex     map[string]int64          // TxID -> index in the log
+	quarantined  map[string]bool
+	sequence     int64 // Last reserved sequence number
+}
+
+// NewMemoryRepository creates an empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+	r := new(MemoryRepository)
+	r.transactions = make(map[string]model.Transaction)
+	r.spentBy = make(map[model.Outpoint]string)
+	r.blockHashes = make(map[string]int64)
+	r.blockTxIDs = make(map[string]int64)
+	r.logIndex = make(map[string]int64)
+	r.quarantined = make(map[string]bool)
+	return r
+}
+
+// SaveTransactions saves all the transactions to the ledger.
+func (r *MemoryRepository) SaveTransactions(transactions []model.Transaction) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.save(transactions)
+}
+
+// SaveBlock saves the block and its transactions.
+func (r *MemoryRepository) SaveBlock(block *model.Block, transactions []model.Transaction) error {
+	if !block.Valid() {
+		return &CommitError{Err: ErrInvalidBlock}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !block.Follows(r.last()) {
+		return &CommitError{Err: ErrBlockConflict}
+	}
+
+	if !block.FollowsTarget(r.last()) {
+		return &CommitError{Err: ErrInvalidBlock}
+	}
+
+	if err := r.save(transactions); err != nil {
+		return err
+	}
+
+	b := *block
+	b.TxIDs = append([]string(nil), block.TxIDs...)
+	r.blocks = append(r.blocks, b)
+	r.blockHashes[b.Hash] = b.Header.Height
+	for _, txID := range b.TxIDs {
+		r.blockTxIDs[txID] = b.Header.Height
+	}
+
+	return nil
+}
+
+// save saves the transactions. The caller must hold the lock.
+func (r *MemoryRepository) save(transactions []model.Transaction) error {
+	batch := make(map[string]*model.Transaction)
+	for i, t := range transactions {
+		if _, contains := r.transactions[t.TxID]; contains || batch[t.TxID] != nil {
+			return &CommitError{TxID: t.TxID, Err: ErrTransactionExists}
+		}
+		batch[t.TxID] = &transactions[i]
+	}
+
+	spent := make(map[model.Outpoint]bool)
+	for _, t := range transactions {
+		for _, input := range t.Inputs {
+			if _, contains := r.spentBy[input.Outpoint]; contains || spent[input.Outpoint] {
+				return &CommitError{TxID: t.TxID, Err: ErrAlreadySpent}
+			}
+			spent[input.Outpoint] = true
+
+			pt, contains := r.transactions[input.Outpoint.TxID]
+			if !contains && batch[input.Outpoint.TxID] != nil {
+				pt = *batch[input.Outpoint.TxID]
+			}
+			if pt.Output(input.Outpoint) == nil {
+				return &CommitError{TxID: t.TxID, Err: ErrMissingOutput}
+			}
+		}
+	}
+
+	for _, t := range transactions {
+		t.NormalizeTimestamp()
+		r.transactions[t.TxID] = t
+		r.logIndex[t.TxID] = int64(len(r.log))
+		r.log = append(r.log, t.TxID)
+
+		for _, input := range t.Inputs {
+			r.spentBy[input.Outpoint] = t.TxID
+		}
+	}
+
+	return nil
+}
+
+// GetTransactions returns the transactions selected by the filter.
+func (r *MemoryRepository) GetTransactions(filter Filter) ([]model.Transaction, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var results []model.Transaction
+	for txID := range r.transactions {
+		t, _ := r.get(txID)
+		if filter.afterCursor(t) && filter.matches(t, r.isSpent(t)) {
+			results = append(results, *t)
+		}
+	}
+
+	filter.sort(results)
+
+	if len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+
+	return results, nil
+}
+
+// GetTransaction returns transaction by TxID.
+func (r *MemoryRepository) GetTransaction(txID string) (*model.Transaction, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.get(txID)
+}
+
+// LookupOutputs returns the transactions of all the outputs in one call.
+func (r *MemoryRepository) LookupOutputs(outpoints []model.Outpoint) (*Lookup, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	lookup := newLookup()
+	for _, outpoint := range uniqueOutpoints(outpoints) {
+		t, _ := r.get(outpoint.TxID)
+		_, spent := r.spentBy[outpoint]
+
+		switch {
+		case t == nil || t.Output(outpoint) == nil || r.quarantined[outpoint.TxID]:
+			lookup.Missing = append(lookup.Missing, outpoint)
+		case spent:
+			lookup.Spent = append(lookup.Spent, outpoint)
+		default:
+			lookup.Unspent[outpoint] = t
+		}
+	}
+
+	return lookup, nil
+}
+
+// GetHistory returns the transaction and the previous transactions of its inputs, recursively.
+func (r *MemoryRepository) GetHistory(txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return history(r.get, txID, depth, offset, limit)
+}
+
+// GetDescendants returns the transaction and the transactions spending its outputs, recursively.
+func (r *MemoryRepository) GetDescendants(txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	spending := func(level []model.Transaction) ([]model.Transaction, error) {
+		var results []model.Transaction
+		for _, t := range level {
+			for i := range t.Outputs {
+				if s, contains := r.spentBy[t.Outpoint(i)]; contains {
+					c, _ := r.get(s)
+					results = append(results, *c)
+				}
+			}
+		}
+		return results, nil
+	}
+
+	return walk(r.get, spending, txID, depth, offset, limit)
+}
+
+// GetBlocks returns the blocks from the height.
+func (r *MemoryRepository) GetBlocks(from int64, limit int, descending bool) ([]model.Block, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var results []model.Block
+	if descending {
+		if from < 0 || from >= int64(len(r.blocks)) {
+			from = int64(len(r.blocks)) - 1
+		}
+		for h := from; h >= 0 && len(results) < limit; h-- {
+			results = append(results, *r.getBlock(h))
+		}
+	} else {
+		if from < 0 {
+			from = 0
+		}
+		for h := from; h < int64(len(r.blocks)) && len(results) < limit; h++ {
+			results = append(results, *r.getBlock(h))
+		}
+	}
+
+	return results, nil
+}
+
+// GetBlock returns the block at the height.
+func (r *MemoryRepository) GetBlock(height int64) (*model.Block, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.getBlock(height), nil
+}
+
+// GetBlockByHash returns the block by hash.
+func (r *MemoryRepository) GetBlockByHash(hash string) (*model.Block, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	height, contains := r.blockHashes[hash]
+	if !contains {
+		return nil, nil
+	}
+
+	return r.getBlock(height), nil
+}
+
+// GetBlockByTxID returns the block containing the transaction.
+func (r *MemoryRepository) GetBlockByTxID(txID string) (*model.Block, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	height, contains := r.blockTxIDs[txID]
+	if !contains {
+		return nil, nil
+	}
+
+	return r.getBlock(height), nil
+}
+
+// LastBlock returns the block with the greatest height.
+func (r *MemoryRepository) LastBlock() (*model.Block, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.last(), nil
+}
+
+// last returns a copy of the last block, or nil. The caller must hold the lock.
+func (r *MemoryRepository) last() *model.Block {
+	return r.getBlock(int64(len(r.blocks)) - 1)
+}
+
+// getBlock returns a copy of the block, or nil if it does not exist. The caller must hold the lock.
+func (r *MemoryRepository) getBlock(height int64) *model.Block {
+	if height < 0 || height >= int64(len(r.blocks)) {
+		return nil
+	}
+
+	b := r.blocks[height]
+	b.TxIDs = append([]string(nil), b.TxIDs...)
+	return &b
+}
+
+// LogSize returns the number of transactions in the log.
+func (r *MemoryRepository) LogSize() (int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return int64(len(r.log)), nil
+}
+
+// ReserveSequence reserves count more sequence numbers and returns the last one.
+func (r *MemoryRepository) ReserveSequence(count int64) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sequence += count
+	return r.sequence, nil
+}
+
+// GetLogEntries returns the TxIDs of the log from start to end.
+func (r *MemoryRepository) GetLogEntries(start int64, end int64) ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if end > int64(len(r.log)) {
+		end = int64(len(r.log))
+	}
+	if start < 0 || start >= end {
+		return nil, nil
+	}
+
+	return append([]string(nil), r.log[start:end]...), nil
+}
+
+// GetLogIndex returns the index of the transaction in the log.
+func (r *MemoryRepository) GetLogIndex(txID string) (int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	index, contains := r.logIndex[txID]
+	if !contains {
+		return -1, nil
+	}
+
+	return index, nil
+}
+
+// Quarantine marks the transactions as quarantined.
+func (r *MemoryRepository) Quarantine(txIDs []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, txID := range txIDs {
+		r.quarantined[txID] = true
+	}
+
+	return nil
+}
+
+// get returns a copy of the transaction, or nil if it does not exist. The caller must hold the lock.
+func (r *MemoryRepository) get(txID string) (*model.Transaction, error) {
+	t, contains := r.transactions[txID]
+	if !contains {
+		return nil, nil
+	}
+
+	t.Inputs = append([]model.Input(nil), t.Inputs...)
+	t.Outputs = append([]model.Output(nil), t.Outputs...)
+	t.Collects = append([]string(nil), t.Collects...)
+	if t.Acceptance != nil {
+		acceptance := *t.Acceptance
+		t.Acceptance = &acceptance
+	}
+	return &t, nil
+}
+
+// isSpent returns true if all the outputs of the transaction are spent. The caller must hold the lock.
+func (r *MemoryRepository) isSpent(t *model.Transaction) bool {
+	for i := range t.Outputs {
+		if _, contains := r.spentBy[t.Outpoint(i)]; !contains {
+			return false
+		}
+	}
+	return true
+}
+
+// Ping does nothing, the ledger in memory is always available.
+func (r *MemoryRepository) Ping() error {
+	return nil
+}
+
+// Close does nothing, the ledger only lives as long as the repository.
+func (r *MemoryRepository) Close() error {
+	return nil
+}
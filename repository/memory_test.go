@@ -0,0 +1,71 @@
+package repository
+
+import "testing"
+
+func TestMemorySaveTransaction(t *testing.T) {
+	testSaveTransaction(t, NewMemoryRepository())
+}
+
+func TestMemoryGetTransactions(t *testing.T) {
+	testGetTransactions(t, NewMemoryRepository())
+}
+
+func TestMemoryGetTransactionIsCopy(t *testing.T) {
+	testGetTransactionIsCopy(t, NewMemoryRepository())
+}
+
+func TestMemorySaveTransactionsBatch(t *testing.T) {
+	testSaveTransactionsBatch(t, NewMemoryRepository())
+}
+
+func TestMemorySaveTransactionsDoubleSpend(t *testing.T) {
+	testSaveTransactionsDoubleSpend(t, NewMemoryRepository())
+}
+
+func TestMemorySaveTransactionsRejected(t *testing.T) {
+	testSaveTransactionsRejected(t, NewMemoryRepository())
+}
+
+func TestMemoryLookupOutputs(t *testing.T) {
+	testLookupOutputs(t, NewMemoryRepository())
+}
+
+func TestMemoryGetHistory(t *testing.T) {
+	testGetHistory(t, NewMemoryRepository())
+}
+
+func TestMemoryGetDescendants(t *testing.T) {
+	testGetDescendants(t, NewMemoryRepository())
+}
+
+func TestMemoryGetTransactionsFilter(t *testing.T) {
+	testGetTransactionsFilter(t, NewMemoryRepository())
+}
+
+func TestMemoryTimestampRoundTrip(t *testing.T) {
+	testTimestampRoundTrip(t, NewMemoryRepository())
+}
+
+func TestMemorySaveBlock(t *testing.T) {
+	testSaveBlock(t, NewMemoryRepository())
+}
+
+func TestMemorySaveBlockTarget(t *testing.T) {
+	testSaveBlockTarget(t, NewMemoryRepository())
+}
+
+func TestMemoryGetBlocks(t *testing.T) {
+	testGetBlocks(t, NewMemoryRepository())
+}
+
+func TestMemoryLog(t *testing.T) {
+	testLog(t, NewMemoryRepository())
+}
+
+func TestMemoryQuarantine(t *testing.T) {
+	testQuarantine(t, NewMemoryRepository())
+}
+
+func TestMemoryAcceptance(t *testing.T) {
+	testAcceptance(t, NewMemoryRepository())
+}
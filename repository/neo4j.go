@@ -0,0 +1,1036 @@
+package repository
+
+import (
+	"cryptocoin-server/model"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	bolt "github.com/johnnadratowski/golang-neo4j-bolt-driver"
+)
+
+// Timestamps are stored in nanoseconds (timestampNs) and, for other readers, in seconds (timestamp).
+// Transactions saved before only have the timestamp in seconds.
+const timestampColumn = "coalesce(n.timestampNs, n.timestamp * 1000000000)"
+
+// transactionColumns are the columns of the transaction n which are read by transactionFromRow.
+const transactionColumns = "n.txId, " + timestampColumn + ", n.inputTxIds, n.inputIndexes, n.inputPubKeys, n.inputSignatures, n.outputAddresses, n.outputValues, n.collects, n.acceptedAtNs, n.sequence, n.timestampNs IS NULL"
+
+// blockColumns are the columns of the block b which are read by blockFromRow.
+const blockColumns = "b.hash, b.height, b.prevHash, b.merkleRoot, b.timestampNs, b.txIds, b.target, b.nonce"
+
+// Neo4jRepository stores the ledger as a graph in Neo4j. A :Transaction node has all the fields of
+// the transaction, the inputs and outputs as lists of their fields. Each output is also an :Output
+// node, (t)-[:OUTPUT]->(o), which is spent by (c)-[:SPENDS]->(o), and (c)-[:PREVIOUS]->(t) connects
+// the transactions. A :Block node has the fields of the block and (b)-[:CONTAINS]->(t) its
+// transactions. Connections are taken from a pool which is shared by all the requests.
+type Neo4jRepository struct {
+	pool    bolt.ClosableDriverPool
+	retries int
+	stop    chan bool // Stops the health checks
+	stopped chan bool
+}
+
+// NewNeo4jRepository creates a connection pool of poolSize connections for the Neo4j server
+// at the bolt URL and checks that the server is available. Operations which fail with a
+// transient error are retried up to retries times. The server is checked again every
+// healthCheckInterval until the repository is closed.
+func NewNeo4jRepository(server string, poolSize int, retries int, healthCheckInterval time.Duration) (*Neo4jRepository, error) {
+	pool, err := bolt.NewClosableDriverPool(server, poolSize)
+	if err != nil {
+		return nil, err
+	}
+
+	r := new(Neo4jRepository)
+	r.pool = pool
+	r.retries = retries
+	r.stop = make(chan bool)
+	r.stopped = make(chan bool)
+
+	err = r.Ping()
+	if err == nil {
+		err = r.migrate()
+	}
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	go r.checkHealth(healthCheckInterval)
+
+	return r, nil
+}
+
+// checkHealth pings the server at the interval until the repository is closed, and logs when the server
+// becomes unavailable and available again. A ping also replaces the broken connections of the pool.
+func (r *Neo4jRepository) checkHealth(interval time.Duration) {
+	defer close(r.stopped)
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	healthy := true
+	for {
+		select {
+		case <-ticker.C:
+			err := r.Ping()
+			if err != nil && healthy {
+				log.Print("Neo4j is unavailable: ", err)
+			} else if err == nil && !healthy {
+				log.Print("Neo4j is available again")
+			}
+			healthy = err == nil
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// migrate indexes the transactions by TxID and converts the nodes saved before transactions had inputs
+// and outputs. Transactions saved before they had a TxID were identified by their signature, which
+// becomes their TxID so references to them keep working. A record (see model.Record) becomes a
+// transaction with one output, spending output 0 of the previous record. The transactions saved before
+// the log are appended to it ordered by timestamp and TxID, and the :Log node counts its entries.
+func (r *Neo4jRepository) migrate() error {
+	for _, query := range []string{
+		"CREATE INDEX ON :Transaction(txId)",
+		"CREATE INDEX ON :Output(txId)",
+		"CREATE CONSTRAINT ON (b:Block) ASSERT b.height IS UNIQUE",
+		"CREATE INDEX ON :Block(hash)",
+		"CREATE INDEX ON :Transaction(logIndex)",
+		`
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.txId IS NULL
+	SET
+	  n.txId = n.signature,
+	  n.prevTxId = n.prevSignature`,
+		`
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.outputValues IS NULL
+	SET
+	  n.inputTxIds = CASE n.prevTxId WHEN 'GENESIS' THEN [] ELSE [n.prevTxId] END,
+	  n.inputIndexes = CASE n.prevTxId WHEN 'GENESIS' THEN [] ELSE [0] END,
+	  n.inputPubKeys = CASE n.prevTxId WHEN 'GENESIS' THEN [] ELSE [n.pubKey] END,
+	  n.inputSignatures = CASE n.prevTxId WHEN 'GENESIS' THEN [] ELSE [n.signature] END,
+	  n.outputAddresses = [n.toAddress],
+	  n.outputValues = [n.value],
+	  n.migrated = true
+	CREATE
+	  (n)-[:OUTPUT]->(:Output {txId: n.txId, index: 0, toAddress: n.toAddress, value: n.value})`,
+		`
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.migrated
+	OPTIONAL MATCH
+	  (n)-[:PREVIOUS]->(:Transaction)-[:OUTPUT]->(o:Output)
+	FOREACH (spent IN CASE WHEN o IS NULL THEN [] ELSE [o] END |
+	  CREATE (n)-[:SPENDS {index: 0}]->(spent))
+	REMOVE
+	  n.migrated`,
+		`
+	MERGE
+	  (l:Log)
+	ON CREATE SET
+	  l.size = 0
+	WITH
+	  l
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.logIndex IS NULL
+	WITH
+	  l, n
+	ORDER BY
+	  ` + timestampColumn + `, n.txId
+	WITH
+	  l, collect(n) AS nodes
+	FOREACH (i IN range(0, size(nodes) - 1) |
+	  FOREACH (n IN [nodes[i]] | SET n.logIndex = l.size + i))
+	SET
+	  l.size = l.size + size(nodes)`,
+	} {
+		if _, err := r.query(query, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ping checks that the Neo4j server answers queries.
+func (r *Neo4jRepository) Ping() error {
+	_, err := r.query("RETURN 1", nil)
+	return err
+}
+
+// Close stops the health checks and closes all the connections of the pool.
+func (r *Neo4jRepository) Close() error {
+	close(r.stop)
+	<-r.stopped
+
+	return r.pool.Close()
+}
+
+// withConn runs fn with a connection from the pool. If fn fails with a transient error
+// (e.g. a deadlock or a broken connection, which the pool discards) it is run again with
+// another connection.
+func (r *Neo4jRepository) withConn(fn func(conn bolt.Conn) error) error {
+	var err error
+
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+
+		var conn bolt.Conn
+		conn, err = r.pool.OpenPool()
+		if err == nil {
+			err = fn(conn)
+			conn.Close()
+		}
+
+		if err == nil || !isTransient(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// query runs the query with a connection from the pool and returns all the rows.
+func (r *Neo4jRepository) query(query string, params map[string]interface{}) ([][]interface{}, error) {
+	var data [][]interface{}
+
+	err := r.withConn(func(conn bolt.Conn) error {
+		var err error
+		data, _, _, err = conn.QueryNeoAll(query, params)
+		return err
+	})
+
+	return data, err
+}
+
+// isTransient returns true if the operation may succeed when it is tried again.
+func isTransient(err error) bool {
+	if errors.Is(err, ErrAlreadySpent) || errors.Is(err, ErrMissingOutput) || errors.Is(err, ErrTransactionExists) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	message := err.Error()
+	for _, transient := range []string{"Neo.TransientError", "broken pipe", "connection reset", "connection refused", "EOF"} {
+		if strings.Contains(message, transient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SaveTransactions saves all the transactions to the ledger in one database transaction.
+func (r *Neo4jRepository) SaveTransactions(transactions []model.Transaction) error {
+	return r.save(nil, transactions)
+}
+
+// SaveBlock saves the block and its transactions in one database transaction.
+func (r *Neo4jRepository) SaveBlock(block *model.Block, transactions []model.Transaction) error {
+	if !block.Valid() {
+		return &CommitError{Err: ErrInvalidBlock}
+	}
+
+	return r.save(block, transactions)
+}
+
+// save saves the transactions, and the block if it is not nil, in one database transaction. The commit
+// may succeed even if the connection breaks before it is acknowledged, so an attempt after a transient
+// error first checks whether the previous attempt saved the batch.
+func (r *Neo4jRepository) save(block *model.Block, transactions []model.Transaction) error {
+	attempted := false
+	err := r.withConn(func(conn bolt.Conn) error {
+		if attempted {
+			if saved, err := isSaved(conn, block, transactions); err != nil || saved {
+				return err
+			}
+		}
+
+		attempted = true
+		return saveTransactions(conn, block, transactions)
+	})
+
+	if err != nil {
+		if _, ok := err.(*CommitError); !ok {
+			err = &CommitError{Err: err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// isSaved returns true if the transactions and the block are saved. They are saved in one database
+// transaction, so they are all saved or none.
+func isSaved(conn bolt.Conn, block *model.Block, transactions []model.Transaction) (bool, error) {
+	query := "MATCH (b:Block) WHERE b.hash = {hash} RETURN count(b)"
+	params := map[string]interface{}{}
+	expected := int64(1)
+
+	if block != nil {
+		params["hash"] = block.Hash
+	}
+
+	if len(transactions) > 0 {
+		txIDs := make([]interface{}, len(transactions))
+		for i, t := range transactions {
+			txIDs[i] = t.TxID
+		}
+
+		query = "MATCH (n:Transaction) WHERE n.txId IN {txIds} RETURN count(n)"
+		params = map[string]interface{}{"txIds": txIDs}
+		expected = int64(len(transactions))
+	} else if block == nil {
+		return true, nil
+	}
+
+	data, _, _, err := conn.QueryNeoAll(query, params)
+	if err != nil || len(data) == 0 {
+		return false, err
+	}
+
+	return data[0][0].(int64) >= expected, nil
+}
+
+// saveTransactions saves the transactions, and the block if it is not nil, in one database transaction
+// on the connection.
+func saveTransactions(conn bolt.Conn, block *model.Block, transactions []model.Transaction) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return &CommitError{Err: err}
+	}
+
+	batch := make(map[string]*model.Transaction)
+	for i, t := range transactions {
+		if batch[t.TxID] != nil {
+			tx.Rollback()
+			return &CommitError{TxID: t.TxID, Err: ErrTransactionExists}
+		}
+		batch[t.TxID] = &transactions[i]
+	}
+
+	// Mark the spent outputs. Setting the property takes a write lock on the node until the end of
+	// the transaction, so a concurrent batch spending the same output waits and then finds the SPENDS
+	// relationship created by this batch. The outputs of the batch are not created yet, and nothing
+	// else can spend them.
+	spent := make(map[model.Outpoint]bool)
+	for _, t := range transactions {
+		for _, input := range t.Inputs {
+			err := spendOutput(conn, input.Outpoint, batch[input.Outpoint.TxID])
+			if err == nil && spent[input.Outpoint] {
+				err = ErrAlreadySpent
+			}
+			if err != nil {
+				tx.Rollback()
+				return &CommitError{TxID: t.TxID, Err: err}
+			}
+			spent[input.Outpoint] = true
+		}
+	}
+
+	// Take the entries of the log. Setting the size locks the :Log node, so the batches are appended one
+	// after the other, and a concurrent batch saving the same transaction is found by the check below.
+	logIndex, err := takeLogEntries(conn, len(transactions))
+	if err != nil {
+		tx.Rollback()
+		return &CommitError{Err: err}
+	}
+
+	if txID, err := savedTxID(conn, transactions); err != nil || txID != "" {
+		tx.Rollback()
+		if err == nil {
+			return &CommitError{TxID: txID, Err: ErrTransactionExists}
+		}
+		return &CommitError{Err: err}
+	}
+
+	// Create all the nodes first, so the relationships can be created between transactions of the same batch
+	for i, t := range transactions {
+		params := transactionParams(&t)
+		params["logIndex"] = logIndex + int64(i)
+
+		_, err := conn.ExecNeo(`
+		CREATE
+		  (n:Transaction {txId: {txId}, logIndex: {logIndex}, timestamp: {timestamp}, timestampNs: {timestampNs}, value: {value},
+		    inputTxIds: {inputTxIds}, inputIndexes: {inputIndexes}, inputPubKeys: {inputPubKeys}, inputSignatures: {inputSignatures},
+		    outputAddresses: {outputAddresses}, outputValues: {outputValues}, collects: {collects},
+		    acceptedAtNs: {acceptedAtNs}, sequence: {sequence}})
+		WITH
+		  n
+		UNWIND
+		  range(0, size({outputAddresses}) - 1) AS i
+		CREATE
+		  (n)-[:OUTPUT]->(:Output {txId: {txId}, index: i, toAddress: {outputAddresses}[i], value: {outputValues}[i]})`,
+			params,
+		)
+		if err != nil {
+			tx.Rollback()
+			return &CommitError{TxID: t.TxID, Err: err}
+		}
+	}
+
+	for _, t := range transactions {
+		for i, input := range t.Inputs {
+			_, err := conn.ExecNeo(`
+			MATCH
+			  (c:Transaction), (p:Transaction)-[:OUTPUT]->(o:Output)
+			WHERE
+			  c.txId = {txId} AND p.txId = {prevTxId} AND o.index = {index}
+			CREATE
+			  (c)-[:SPENDS {index: {input}}]->(o)
+			MERGE
+			  (c)-[:PREVIOUS]->(p)`,
+				map[string]interface{}{"txId": t.TxID, "prevTxId": input.Outpoint.TxID, "index": input.Outpoint.Index, "input": i},
+			)
+			if err != nil {
+				tx.Rollback()
+				return &CommitError{TxID: t.TxID, Err: err}
+			}
+		}
+	}
+
+	if block != nil {
+		if err := saveBlock(conn, block); err != nil {
+			tx.Rollback()
+			return &CommitError{Err: err}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return &CommitError{Err: err}
+	}
+
+	return nil
+}
+
+// spendOutput marks the output as spent, and returns ErrAlreadySpent if a transaction spends it or
+// ErrMissingOutput if it does not exist. batchTransaction is the transaction of the output if it is in
+// the batch being saved, or nil.
+func spendOutput(conn bolt.Conn, outpoint model.Outpoint, batchTransaction *model.Transaction) error {
+	if batchTransaction != nil {
+		if batchTransaction.Output(outpoint) == nil {
+			return ErrMissingOutput
+		}
+		return nil
+	}
+
+	data, _, _, err := conn.QueryNeoAll(`
+	MATCH
+	  (o:Output)
+	WHERE
+	  o.txId = {txId} AND o.index = {index}
+	SET
+	  o.spent = true
+	WITH
+	  o
+	OPTIONAL MATCH
+	  (c:Transaction)-[:SPENDS]->(o)
+	RETURN
+	  o.index, count(c)`,
+		map[string]interface{}{"txId": outpoint.TxID, "index": outpoint.Index},
+	)
+
+	switch {
+	case err != nil:
+		return err
+	case len(data) == 0:
+		return ErrMissingOutput
+	case data[0][1].(int64) > 0:
+		return ErrAlreadySpent
+	}
+
+	return nil
+}
+
+// savedTxID returns the TxID of a transaction which is already saved, or an empty string.
+func savedTxID(conn bolt.Conn, transactions []model.Transaction) (string, error) {
+	txIDs := make([]interface{}, len(transactions))
+	for i, t := range transactions {
+		txIDs[i] = t.TxID
+	}
+
+	data, _, _, err := conn.QueryNeoAll(`
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.txId IN {txIds}
+	RETURN
+	  n.txId
+	LIMIT 1`,
+		map[string]interface{}{"txIds": txIDs},
+	)
+	if err != nil || len(data) == 0 {
+		return "", err
+	}
+
+	return data[0][0].(string), nil
+}
+
+// takeLogEntries adds count entries to the log and returns the index of the first one.
+func takeLogEntries(conn bolt.Conn, count int) (int64, error) {
+	data, _, _, err := conn.QueryNeoAll(`
+	MATCH
+	  (l:Log)
+	SET
+	  l.size = l.size + {count}
+	RETURN
+	  l.size`,
+		map[string]interface{}{"count": int64(count)},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) == 0 {
+		return 0, errors.New("Log not found")
+	}
+
+	return data[0][0].(int64) - int64(count), nil
+}
+
+// saveBlock creates the :Block node of the block, connected to its transactions. The unique height
+// makes a concurrent block at the same height fail.
+func saveBlock(conn bolt.Conn, block *model.Block) error {
+	data, _, _, err := conn.QueryNeoAll(`
+	MATCH
+	  (b:Block)
+	RETURN
+	  `+blockColumns+`
+	ORDER BY
+	  b.height DESC
+	LIMIT 1`, nil)
+	if err != nil {
+		return err
+	}
+
+	var last *model.Block
+	if len(data) > 0 {
+		b := blockFromRow(data[0])
+		last = &b
+	}
+
+	if !block.Follows(last) {
+		return ErrBlockConflict
+	}
+
+	if !block.FollowsTarget(last) {
+		return ErrInvalidBlock
+	}
+
+	txIDs := make([]interface{}, len(block.TxIDs))
+	for i, txID := range block.TxIDs {
+		txIDs[i] = txID
+	}
+
+	_, err = conn.ExecNeo(`
+	CREATE
+	  (b:Block {hash: {hash}, height: {height}, prevHash: {prevHash}, merkleRoot: {merkleRoot},
+	    timestamp: {timestamp}, timestampNs: {timestampNs}, txIds: {txIds}, target: {target}, nonce: {nonce}})
+	WITH
+	  b
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.txId IN {txIds}
+	CREATE
+	  (b)-[:CONTAINS]->(n)`,
+		map[string]interface{}{
+			"hash":        block.Hash,
+			"height":      block.Header.Height,
+			"prevHash":    block.Header.PrevHash,
+			"merkleRoot":  block.Header.MerkleRoot,
+			"timestamp":   block.Header.Timestamp.Unix(),
+			"timestampNs": block.Header.Timestamp.UnixNano(),
+			"txIds":       txIDs,
+			"target":      block.Header.Target,
+			"nonce":       int64(block.Header.Nonce), // Neo4j integers are signed, the bits are the same
+		},
+	)
+	if err != nil && strings.Contains(err.Error(), "ConstraintValidationFailed") {
+		return ErrBlockConflict
+	}
+
+	return err
+}
+
+// transactionParams returns the properties of the :Transaction node of the transaction.
+func transactionParams(t *model.Transaction) map[string]interface{} {
+	inputTxIds := make([]interface{}, len(t.Inputs))
+	inputIndexes := make([]interface{}, len(t.Inputs))
+	inputPubKeys := make([]interface{}, len(t.Inputs))
+	inputSignatures := make([]interface{}, len(t.Inputs))
+	for i, input := range t.Inputs {
+		inputTxIds[i] = input.Outpoint.TxID
+		inputIndexes[i] = int64(input.Outpoint.Index)
+		inputPubKeys[i] = input.PubKey
+		inputSignatures[i] = input.Signature
+	}
+
+	outputAddresses := make([]interface{}, len(t.Outputs))
+	outputValues := make([]interface{}, len(t.Outputs))
+	for i, output := range t.Outputs {
+		outputAddresses[i] = output.ToAddress
+		outputValues[i] = output.Value
+	}
+
+	collects := make([]interface{}, len(t.Collects))
+	for i, txID := range t.Collects {
+		collects[i] = txID
+	}
+
+	// A transaction which was not accepted by the server has no acceptance, the properties are not set
+	var acceptedAt, sequence interface{}
+	if t.Acceptance != nil {
+		acceptedAt = t.Acceptance.AcceptedAt.UnixNano()
+		sequence = t.Acceptance.Sequence
+	}
+
+	return map[string]interface{}{
+		"txId":            t.TxID,
+		"timestamp":       t.Timestamp.Unix(),
+		"timestampNs":     t.Timestamp.UnixNano(),
+		"value":           t.OutputValue(),
+		"inputTxIds":      inputTxIds,
+		"inputIndexes":    inputIndexes,
+		"inputPubKeys":    inputPubKeys,
+		"inputSignatures": inputSignatures,
+		"outputAddresses": outputAddresses,
+		"outputValues":    outputValues,
+		"collects":        collects,
+		"acceptedAtNs":    acceptedAt,
+		"sequence":        sequence,
+	}
+}
+
+// GetTransactions returns the transactions selected by the filter.
+func (r *Neo4jRepository) GetTransactions(filter Filter) ([]model.Transaction, error) {
+	var where []string
+	params := map[string]interface{}{"limit": filter.Limit}
+
+	if filter.ToAddress != "" {
+		where = append(where, "{toAddress} IN n.outputAddresses")
+		params["toAddress"] = filter.ToAddress
+	}
+
+	if filter.PubKey != "" {
+		where = append(where, "{pubKey} IN n.inputPubKeys")
+		params["pubKey"] = filter.PubKey
+	}
+
+	if !filter.From.IsZero() {
+		where = append(where, timestampColumn+" >= {from}")
+		params["from"] = filter.From.UnixNano()
+	}
+
+	if !filter.To.IsZero() {
+		where = append(where, timestampColumn+" < {to}")
+		params["to"] = filter.To.UnixNano()
+	}
+
+	if filter.MinValue != nil {
+		where = append(where, "n.value >= {minValue}")
+		params["minValue"] = *filter.MinValue
+	}
+
+	if filter.MaxValue != nil {
+		where = append(where, "n.value <= {maxValue}")
+		params["maxValue"] = *filter.MaxValue
+	}
+
+	if filter.Spent != nil && *filter.Spent {
+		where = append(where, "ALL(o IN [(n)-[:OUTPUT]->(o) | o] WHERE ()-[:SPENDS]->(o))")
+	} else if filter.Spent != nil {
+		where = append(where, "ANY(o IN [(n)-[:OUTPUT]->(o) | o] WHERE NOT ()-[:SPENDS]->(o))")
+	}
+
+	order := "ASC"
+	operator := ">"
+	if filter.Descending {
+		order = "DESC"
+		operator = "<"
+	}
+
+	if filter.After != nil {
+		where = append(where, "("+timestampColumn+" "+operator+" {afterTimestamp} OR ("+timestampColumn+" = {afterTimestamp} AND n.txId "+operator+" {afterTxId}))")
+		params["afterTimestamp"] = filter.After.Timestamp.UnixNano()
+		params["afterTxId"] = filter.After.TxID
+	}
+
+	query := `
+	MATCH
+	  (n:Transaction)`
+
+	if len(where) > 0 {
+		query += `
+	WHERE
+	  ` + strings.Join(where, " AND ")
+	}
+
+	query += `
+	RETURN
+	  ` + transactionColumns + `
+	ORDER BY
+	  ` + timestampColumn + ` ` + order + `, n.txId ` + order + `
+	LIMIT {limit}`
+
+	return r.queryTransactions(query, params)
+}
+
+// GetTransaction returns transaction by TxID.
+func (r *Neo4jRepository) GetTransaction(txID string) (*model.Transaction, error) {
+	query := `
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.txId = {txId}
+	RETURN
+	  ` + transactionColumns + `
+	LIMIT {limit}`
+
+	data, err := r.query(query, map[string]interface{}{"txId": txID, "limit": 1})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	transaction := transactionFromRow(data[0])
+
+	return &transaction, nil
+}
+
+// LookupOutputs returns the transactions of all the outputs in one query.
+func (r *Neo4jRepository) LookupOutputs(outpoints []model.Outpoint) (*Lookup, error) {
+	outpoints = uniqueOutpoints(outpoints)
+
+	params := make([]interface{}, len(outpoints))
+	for i, outpoint := range outpoints {
+		params[i] = map[string]interface{}{"txId": outpoint.TxID, "index": outpoint.Index}
+	}
+
+	query := `
+	UNWIND {outpoints} AS outpoint
+	MATCH
+	  (n:Transaction)-[:OUTPUT]->(o:Output)
+	WHERE
+	  n.txId = outpoint.txId AND o.index = outpoint.index AND NOT n:Quarantined
+	RETURN
+	  ` + transactionColumns + `, o.index, EXISTS(()-[:SPENDS]->(o))`
+
+	data, err := r.query(query, map[string]interface{}{"outpoints": params})
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := newLookup()
+	found := make(map[model.Outpoint]bool)
+
+	for _, row := range data {
+		t := transactionFromRow(row)
+		outpoint := t.Outpoint(int(row[12].(int64)))
+		found[outpoint] = true
+
+		if row[13].(bool) {
+			lookup.Spent = append(lookup.Spent, outpoint)
+		} else {
+			lookup.Unspent[outpoint] = &t
+		}
+	}
+
+	for _, outpoint := range outpoints {
+		if !found[outpoint] {
+			lookup.Missing = append(lookup.Missing, outpoint)
+		}
+	}
+
+	return lookup, nil
+}
+
+// GetHistory returns the transaction and the previous transactions of its inputs, recursively. The graph
+// is walked one level at a time, so a transaction reached by several paths is only read once.
+func (r *Neo4jRepository) GetHistory(txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	return walk(r.GetTransaction, r.connected("(s:Transaction)-[:PREVIOUS]->(n:Transaction)"), txID, depth, offset, limit)
+}
+
+// GetDescendants returns the transaction and the transactions spending its outputs, recursively, one
+// level at a time like GetHistory.
+func (r *Neo4jRepository) GetDescendants(txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	return walk(r.GetTransaction, r.connected("(s:Transaction)<-[:PREVIOUS]-(n:Transaction)"), txID, depth, offset, limit)
+}
+
+// connected returns the function of walk which reads the transactions n connected to the transactions s
+// of a level by the pattern, in one query.
+func (r *Neo4jRepository) connected(pattern string) func(level []model.Transaction) ([]model.Transaction, error) {
+	query := `
+	MATCH
+	  ` + pattern + `
+	WHERE
+	  s.txId IN {txIds}
+	RETURN DISTINCT
+	  ` + transactionColumns
+
+	return func(level []model.Transaction) ([]model.Transaction, error) {
+		txIDs := make([]interface{}, len(level))
+		for i, t := range level {
+			txIDs[i] = t.TxID
+		}
+
+		return r.queryTransactions(query, map[string]interface{}{"txIds": txIDs})
+	}
+}
+
+// GetBlocks returns the blocks from the height.
+func (r *Neo4jRepository) GetBlocks(from int64, limit int, descending bool) ([]model.Block, error) {
+	where, order := "b.height >= {from}", "ASC"
+	if descending {
+		where, order = "({from} < 0 OR b.height <= {from})", "DESC"
+	}
+
+	query := `
+	MATCH
+	  (b:Block)
+	WHERE
+	  ` + where + `
+	RETURN
+	  ` + blockColumns + `
+	ORDER BY
+	  b.height ` + order + `
+	LIMIT {limit}`
+
+	return r.queryBlocks(query, map[string]interface{}{"from": from, "limit": limit})
+}
+
+// GetBlock returns the block at the height.
+func (r *Neo4jRepository) GetBlock(height int64) (*model.Block, error) {
+	return r.queryBlock("MATCH (b:Block) WHERE b.height = {height} RETURN "+blockColumns, map[string]interface{}{"height": height})
+}
+
+// GetBlockByHash returns the block by hash.
+func (r *Neo4jRepository) GetBlockByHash(hash string) (*model.Block, error) {
+	return r.queryBlock("MATCH (b:Block) WHERE b.hash = {hash} RETURN "+blockColumns, map[string]interface{}{"hash": hash})
+}
+
+// GetBlockByTxID returns the block containing the transaction.
+func (r *Neo4jRepository) GetBlockByTxID(txID string) (*model.Block, error) {
+	return r.queryBlock("MATCH (b:Block)-[:CONTAINS]->(n:Transaction) WHERE n.txId = {txId} RETURN "+blockColumns, map[string]interface{}{"txId": txID})
+}
+
+// LastBlock returns the block with the greatest height.
+func (r *Neo4jRepository) LastBlock() (*model.Block, error) {
+	return r.queryBlock("MATCH (b:Block) RETURN "+blockColumns+" ORDER BY b.height DESC LIMIT 1", nil)
+}
+
+// Quarantine adds the :Quarantined label to the transactions.
+func (r *Neo4jRepository) Quarantine(txIDs []string) error {
+	params := make([]interface{}, len(txIDs))
+	for i, txID := range txIDs {
+		params[i] = txID
+	}
+
+	_, err := r.query("MATCH (n:Transaction) WHERE n.txId IN {txIds} SET n:Quarantined", map[string]interface{}{"txIds": params})
+	return err
+}
+
+// LogSize returns the number of transactions in the log.
+func (r *Neo4jRepository) LogSize() (int64, error) {
+	data, err := r.query("MATCH (l:Log) RETURN l.size", nil)
+	if len(data) == 0 || err != nil {
+		return 0, err
+	}
+
+	return data[0][0].(int64), nil
+}
+
+// ReserveSequence reserves count more sequence numbers, kept on the :Log node, and returns the last one. A
+// retried query may reserve the numbers twice, which only leaves a gap.
+func (r *Neo4jRepository) ReserveSequence(count int64) (int64, error) {
+	data, err := r.query(`
+	MATCH
+	  (l:Log)
+	SET
+	  l.sequence = coalesce(l.sequence, 0) + {count}
+	RETURN
+	  l.sequence`,
+		map[string]interface{}{"count": count},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, errors.New("Log node not found")
+	}
+
+	return data[0][0].(int64), nil
+}
+
+// GetLogEntries returns the TxIDs of the log from start to end.
+func (r *Neo4jRepository) GetLogEntries(start int64, end int64) ([]string, error) {
+	data, err := r.query(`
+	MATCH
+	  (n:Transaction)
+	WHERE
+	  n.logIndex >= {start} AND n.logIndex < {end}
+	RETURN
+	  n.txId
+	ORDER BY
+	  n.logIndex`,
+		map[string]interface{}{"start": start, "end": end},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []string
+	for _, row := range data {
+		results = append(results, row[0].(string))
+	}
+
+	return results, nil
+}
+
+// GetLogIndex returns the index of the transaction in the log.
+func (r *Neo4jRepository) GetLogIndex(txID string) (int64, error) {
+	data, err := r.query("MATCH (n:Transaction) WHERE n.txId = {txId} RETURN n.logIndex LIMIT 1", map[string]interface{}{"txId": txID})
+	if len(data) == 0 || err != nil {
+		return -1, err
+	}
+
+	return data[0][0].(int64), nil
+}
+
+// queryBlock runs a query returning the block columns of blockFromRow, nil if there is no block.
+func (r *Neo4jRepository) queryBlock(query string, params map[string]interface{}) (*model.Block, error) {
+	blocks, err := r.queryBlocks(query, params)
+	if len(blocks) == 0 || err != nil {
+		return nil, err
+	}
+
+	return &blocks[0], nil
+}
+
+// queryBlocks runs a query returning the block columns of blockFromRow.
+func (r *Neo4jRepository) queryBlocks(query string, params map[string]interface{}) ([]model.Block, error) {
+	data, err := r.query(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []model.Block
+	for _, row := range data {
+		results = append(results, blockFromRow(row))
+	}
+
+	return results, nil
+}
+
+// queryTransactions runs a query returning the transaction columns of transactionFromRow.
+func (r *Neo4jRepository) queryTransactions(query string, params map[string]interface{}) ([]model.Transaction, error) {
+	data, err := r.query(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	results := make([]model.Transaction, len(data))
+	for i, row := range data {
+		results[i] = transactionFromRow(row)
+	}
+
+	return results, nil
+}
+
+// transactionFromRow maps the transactionColumns of a row to a transaction.
+func transactionFromRow(row []interface{}) model.Transaction {
+	t := model.Transaction{
+		TxID:      row[0].(string),
+		Timestamp: time.Unix(0, row[1].(int64)).UTC(),
+
+		// Transactions saved before timestampNs only have the timestamp in seconds
+		TimestampInSeconds: row[11].(bool),
+	}
+
+	inputTxIds := list(row[2])
+	inputIndexes := list(row[3])
+	inputPubKeys := list(row[4])
+	inputSignatures := list(row[5])
+	for i := range inputTxIds {
+		t.Inputs = append(t.Inputs, model.Input{
+			Outpoint:  model.Outpoint{TxID: inputTxIds[i].(string), Index: int(inputIndexes[i].(int64))},
+			PubKey:    inputPubKeys[i].(string),
+			Signature: inputSignatures[i].(string),
+		})
+	}
+
+	outputAddresses := list(row[6])
+	outputValues := list(row[7])
+	for i := range outputAddresses {
+		t.Outputs = append(t.Outputs, model.Output{ToAddress: outputAddresses[i].(string), Value: outputValues[i].(int64)})
+	}
+
+	for _, txID := range list(row[8]) {
+		t.Collects = append(t.Collects, txID.(string))
+	}
+
+	// Transactions saved before the acceptance was kept do not have it
+	if acceptedAt, ok := row[9].(int64); ok {
+		sequence, _ := row[10].(int64)
+		t.Acceptance = &model.Acceptance{AcceptedAt: time.Unix(0, acceptedAt).UTC(), Sequence: sequence}
+	}
+
+	return t
+}
+
+// blockFromRow maps the blockColumns of a row to a block.
+func blockFromRow(row []interface{}) model.Block {
+	b := model.Block{Hash: row[0].(string)}
+	b.Header.Height = row[1].(int64)
+	b.Header.PrevHash = row[2].(string)
+	b.Header.MerkleRoot = row[3].(string)
+	b.Header.Timestamp = time.Unix(0, row[4].(int64)).UTC()
+
+	for _, txID := range list(row[5]) {
+		b.TxIDs = append(b.TxIDs, txID.(string))
+	}
+
+	// Blocks saved before proof of work do not have a target and a nonce
+	b.Header.Target, _ = row[6].(string)
+	nonce, _ := row[7].(int64)
+	b.Header.Nonce = uint64(nonce)
+
+	return b
+}
+
+// list returns the values of a list column, which is null for an empty list.
+func list(column interface{}) []interface{} {
+	values, _ := column.([]interface{})
+	return values
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrAlreadySpent is returned when an output of a previous transaction is already spent by another transaction.
+var ErrAlreadySpent = errors.New("Previous transaction is already used")
+
+// ErrMissingOutput is returned when an input spends an output which does not exist.
+var ErrMissingOutput = errors.New("Previous output does not exist")
+
+// ErrTransactionExists is returned when a transaction with the same TxID is already saved.
+var ErrTransactionExists = errors.New("Transaction already exists")
+
+// ErrBlockConflict is returned when a block does not follow the last block, e.g. another block was saved first.
+var ErrBlockConflict = errors.New("Block does not follow the last block")
+
+// ErrInvalidBlock is returned when the hash of a block does not match its header and transactions, does
+// not meet its proof of work target, or its target may not follow the target of the last block.
+var ErrInvalidBlock = errors.New("Block is not valid")
+
+// Repository is the storage backend of the ledger. Each transaction is stored as one unit with its
+// inputs and outputs. An output which is referenced by an input of another transaction is spent, and
+// the transactions are connected by a PREVIOUS relationship. Saved transactions are appended to the
+// log, in the same database transaction.
+type Repository interface {
+	// SaveTransactions saves all the transactions to the ledger, or none of them.
+	// A *CommitError is returned if the transactions could not be saved. The check that
+	// the outputs spent by the inputs are not spent yet is repeated in the same database transaction,
+	// so concurrent batches spending the same output fail with ErrAlreadySpent, as do two transactions
+	// of the batch spending the same output. An input spending an output which is neither saved nor in
+	// the batch fails with ErrMissingOutput, and a transaction which is already saved with
+	// ErrTransactionExists.
+	SaveTransactions(transactions []model.Transaction) error
+
+	// SaveBlock saves the block and its transactions, in the order of the block, like SaveTransactions
+	// in the same database transaction. The block must be valid (see model.Block.Valid) and its target
+	// must follow the target of the last block (see model.Block.FollowsTarget), otherwise a *CommitError
+	// with ErrInvalidBlock is returned, and follow the last block (see model.Block.Follows), otherwise
+	// a *CommitError with ErrBlockConflict is returned.
+	SaveBlock(block *model.Block, transactions []model.Transaction) error
+
+	// GetBlocks returns at most limit blocks from the height, ordered by height. Descending blocks start
+	// at the last block if from is negative.
+	GetBlocks(from int64, limit int, descending bool) ([]model.Block, error)
+
+	// GetBlock returns the block at the height, or nil if it does not exist.
+	GetBlock(height int64) (*model.Block, error)
+
+	// GetBlockByHash returns the block by hash, or nil if it does not exist.
+	GetBlockByHash(hash string) (*model.Block, error)
+
+	// GetBlockByTxID returns the block containing the transaction, or nil if the transaction is not in a block.
+	GetBlockByTxID(txID string) (*model.Block, error)
+
+	// LastBlock returns the block with the greatest height, or nil if there are no blocks.
+	LastBlock() (*model.Block, error)
+
+	// GetTransactions returns at most filter.Limit transactions selected by the filter, ordered
+	// by timestamp and TxID.
+	GetTransactions(filter Filter) ([]model.Transaction, error)
+
+	// GetTransaction returns transaction by TxID, or nil if it does not exist.
+	GetTransaction(txID string) (*model.Transaction, error)
+
+	// LookupOutputs returns the transactions of all the outputs in one call, split into unspent,
+	// spent and missing outputs.
+	LookupOutputs(outpoints []model.Outpoint) (*Lookup, error)
+
+	// GetHistory returns the transaction and the previous transactions of its inputs, recursively back to
+	// GENESIS, ordered by depth, timestamp and TxID. Transactions more than depth PREVIOUS relationships
+	// away are not included (no limit if depth is negative). The result is paginated by skipping offset
+	// transactions and returning at most limit.
+	GetHistory(txID string, depth int, offset int, limit int) ([]model.Transaction, error)
+
+	// GetDescendants returns the transaction and the transactions spending its outputs, recursively,
+	// ordered by depth, timestamp and TxID. Depth and pagination are the same as GetHistory.
+	GetDescendants(txID string, depth int, offset int, limit int) ([]model.Transaction, error)
+
+	// LogSize returns the number of transactions in the log: every saved transaction in the order it was
+	// saved, which is never changed.
+	LogSize() (int64, error)
+
+	// GetLogEntries returns the TxIDs of the log from index start to index end, excluded.
+	GetLogEntries(start int64, end int64) ([]string, error)
+
+	// GetLogIndex returns the index of the transaction in the log, or -1 if it is not in the log.
+	GetLogIndex(txID string) (int64, error)
+
+	// ReserveSequence reserves count more ledger sequence numbers (see model.Acceptance) and returns the
+	// last one. The reserved numbers are never reserved again, even if they were not used.
+	ReserveSequence(count int64) (int64, error)
+
+	// Quarantine marks the transactions as quarantined: they are kept, but their outputs are missing for
+	// LookupOutputs so they can not be spent.
+	Quarantine(txIDs []string) error
+
+	// Ping checks that the storage is available.
+	Ping() error
+
+	// Close releases the connections or files used by the repository.
+	Close() error
+}
+
+// Lookup is the result of looking up several outputs at once.
+type Lookup struct {
+	Unspent map[model.Outpoint]*model.Transaction // Transactions of the outputs which are not spent yet
+	Spent   []model.Outpoint                      // Outputs which are already spent
+	Missing []model.Outpoint                      // Outputs which do not exist in the ledger
+}
+
+func newLookup() *Lookup {
+	l := new(Lookup)
+	l.Unspent = make(map[model.Outpoint]*model.Transaction)
+	return l
+}
+
+// CommitError is returned when a batch of transactions could not be saved. The batch was rolled back.
+type CommitError struct {
+	TxID string // Transaction which failed to save, empty if the whole batch failed
+	Err  error
+}
+
+func (e *CommitError) Error() string {
+	if e.TxID == "" {
+		return "Transactions could not be saved: " + e.Err.Error()
+	}
+
+	return "Transaction " + e.TxID + " could not be saved: " + e.Err.Error()
+}
+
+// Unwrap returns the storage error.
+func (e *CommitError) Unwrap() error {
+	return e.Err
+}
+
+// InitRepository creates the repository selected in the config.
+func InitRepository(config *config.Config) (Repository, error) {
+	switch config.Repository {
+	case "neo4j":
+		r, err := NewNeo4jRepository(config.Neo4jServer, config.Neo4jPoolSize, config.Neo4jRetries, config.Neo4jHealthCheckInterval)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case "bbolt":
+		r, err := NewBBoltRepository(config.BBoltPath)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case "memory":
+		return NewMemoryRepository(), nil
+	}
+
+	return nil, fmt.Errorf("Unknown repository %q", config.Repository)
+}
+
+// uniqueOutpoints returns the outpoints without duplicates, in the original order.
+func uniqueOutpoints(outpoints []model.Outpoint) []model.Outpoint {
+	seen := make(map[model.Outpoint]bool)
+	results := make([]model.Outpoint, 0, len(outpoints))
+
+	for _, o := range outpoints {
+		if !seen[o] {
+			seen[o] = true
+			results = append(results, o)
+		}
+	}
+
+	return results
+}
+
+// history walks the previous transactions of the inputs for the repositories which do not have a graph.
+// get returns nil if the transaction does not exist.
+func history(get func(txID string) (*model.Transaction, error), txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	previous := func(level []model.Transaction) ([]model.Transaction, error) {
+		var results []model.Transaction
+		for _, t := range level {
+			for _, input := range t.Inputs {
+				pt, err := get(input.Outpoint.TxID)
+				if err != nil {
+					return nil, err
+				}
+				if pt != nil {
+					results = append(results, *pt)
+				}
+			}
+		}
+		return results, nil
+	}
+
+	return walk(get, previous, txID, depth, offset, limit)
+}
+
+// walk walks the transaction graph breadth first, one level at a time. next returns the transactions
+// connected to the transactions of a level. A transaction reached by several paths is only returned at
+// the lowest depth, so each transaction is visited once and the walk stops when the page is complete.
+func walk(get func(txID string) (*model.Transaction, error), next func(level []model.Transaction) ([]model.Transaction, error), txID string, depth int, offset int, limit int) ([]model.Transaction, error) {
+	var results []model.Transaction
+
+	t, err := get(txID)
+	if t == nil || err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{t.TxID: true}
+	level := []model.Transaction{*t}
+	for d := 0; len(level) > 0 && (depth < 0 || d <= depth) && len(results) < offset+limit; d++ {
+		results = append(results, level...)
+
+		// The next level is not read if it is not returned
+		if depth >= 0 && d == depth || len(results) >= offset+limit {
+			break
+		}
+
+		connected, err := next(level)
+		if err != nil {
+			return nil, err
+		}
+
+		var nextLevel []model.Transaction
+		for _, c := range connected {
+			if !seen[c.TxID] {
+				seen[c.TxID] = true
+				nextLevel = append(nextLevel, c)
+			}
+		}
+
+		sort.Slice(nextLevel, func(i, j int) bool {
+			if nextLevel[i].Timestamp.Equal(nextLevel[j].Timestamp) {
+				return nextLevel[i].TxID < nextLevel[j].TxID
+			}
+			return nextLevel[i].Timestamp.Before(nextLevel[j].Timestamp)
+		})
+		level = nextLevel
+	}
+
+	return page(results, offset, limit), nil
+}
+
+// page returns at most limit transactions after skipping offset transactions.
+func page(transactions []model.Transaction, offset int, limit int) []model.Transaction {
+	if offset >= len(transactions) {
+		return nil
+	}
+
+	transactions = transactions[offset:]
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+	}
+
+	return transactions
+}
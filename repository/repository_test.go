@@ -0,0 +1,588 @@
+package repository
+
+import (
+	"cryptocoin-server/model"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The tests below are shared by all the repository implementations.
+
+// outpoint returns output index of the transaction.
+func outpoint(txID string, index int) model.Outpoint {
+	return model.Outpoint{TxID: txID, Index: index}
+}
+
+// spend returns a transaction spending the outputs, with one output of each value to address x.
+func spend(txID string, timestamp time.Time, outpoints []model.Outpoint, values ...int64) model.Transaction {
+	t := model.Transaction{TxID: txID, Timestamp: timestamp}
+	for _, o := range outpoints {
+		t.Inputs = append(t.Inputs, model.Input{Outpoint: o, PubKey: "x"})
+	}
+	for _, value := range values {
+		t.Outputs = append(t.Outputs, model.Output{ToAddress: "x", Value: value})
+	}
+	return t
+}
+
+func testSaveTransaction(t *testing.T, r Repository) {
+	pt := spend("a", time.Now(), nil, 10)
+	nt := spend("b", time.Now(), []model.Outpoint{outpoint("a", 0)}, 10)
+	nt.Outputs[0].ToAddress = "y"
+
+	r.SaveTransactions([]model.Transaction{pt})
+
+	if result, err := r.GetTransaction("a"); result == nil || result.OutputValue() != 10 || err != nil {
+		t.Error("Transaction not found:", result, err)
+	}
+
+	r.SaveTransactions([]model.Transaction{nt})
+
+	if lookup, err := r.LookupOutputs([]model.Outpoint{outpoint("a", 0)}); len(lookup.Spent) != 1 || err != nil {
+		t.Error("Spent output not returned:", lookup, err)
+	}
+
+	if result, err := r.GetTransaction("b"); result == nil || len(result.Inputs) != 1 || result.Inputs[0].Outpoint != outpoint("a", 0) || result.Inputs[0].PubKey != "x" || err != nil {
+		t.Error("Inputs do not match:", result, err)
+	}
+
+	if result, err := r.GetTransaction("c"); result != nil || err != nil {
+		t.Error("Unknown transaction found:", result, err)
+	}
+}
+
+func testGetTransactions(t *testing.T, r Repository) {
+	now := time.Now()
+
+	// Two transactions per timestamp, so the TxID decides the order
+	for i := 0; i < 30; i++ {
+		r.SaveTransactions([]model.Transaction{spend(string(rune('a'+i)), now.Add(time.Duration(i/2)*time.Second), nil, int64(i))})
+	}
+
+	results, err := r.GetTransactions(Filter{Descending: true, Limit: 25})
+
+	if len(results) != 25 || results[0].OutputValue() != 29 || results[24].OutputValue() != 5 || err != nil {
+		t.Error("Latest transactions do not match:", len(results), err)
+	}
+
+	// Page through the whole ledger in both orders
+	for _, descending := range []bool{false, true} {
+		var cursor *Cursor
+		var values []int64
+
+		for {
+			results, err := r.GetTransactions(Filter{Descending: descending, After: cursor, Limit: 7})
+			if err != nil || len(results) == 0 {
+				break
+			}
+
+			for _, result := range results {
+				values = append(values, result.OutputValue())
+			}
+			cursor = NewCursor(&results[len(results)-1])
+		}
+
+		if len(values) != 30 {
+			t.Fatal("Pages do not cover the ledger:", descending, values)
+		}
+
+		for i, value := range values {
+			expected := int64(i)
+			if descending {
+				expected = int64(29 - i)
+			}
+
+			if value != expected {
+				t.Error("Pages are not in order:", descending, values)
+				break
+			}
+		}
+	}
+}
+
+func testGetTransactionsFilter(t *testing.T, r Repository) {
+	now := time.Now()
+	b := spend("b", now.Add(time.Second), []model.Outpoint{outpoint("a", 0)}, 6, 4)
+	b.Outputs[0].ToAddress = "y"
+	c := spend("c", now.Add(time.Second), []model.Outpoint{outpoint("b", 1)}, 4)
+	c.Outputs[0].ToAddress = "z"
+
+	r.SaveTransactions([]model.Transaction{spend("a", now, nil, 10)})
+	r.SaveTransactions([]model.Transaction{b})
+	r.SaveTransactions([]model.Transaction{c})
+
+	unspent := false
+	spent := true
+	minValue := int64(5)
+	maxValue := int64(6)
+
+	tests := []struct {
+		filter Filter
+		txIDs  string
+	}{
+		{Filter{}, "abc"},
+		{Filter{ToAddress: "x"}, "ab"},
+		{Filter{ToAddress: "y"}, "b"},
+		{Filter{PubKey: "x"}, "bc"},
+		{Filter{From: now.Add(time.Second)}, "bc"},
+		{Filter{To: now.Add(time.Second)}, "a"},
+		{Filter{MinValue: &minValue}, "ab"},
+		{Filter{MaxValue: &maxValue}, "c"},
+		{Filter{MinValue: &minValue, MaxValue: &maxValue}, ""},
+		{Filter{Spent: &unspent}, "bc"},
+		{Filter{Spent: &spent}, "a"},
+		{Filter{ToAddress: "z", Spent: &unspent}, "c"},
+		{Filter{Descending: true, From: now.Add(time.Second)}, "cb"},
+		{Filter{Descending: true, To: now.Add(time.Second)}, "a"},
+		{Filter{ToAddress: "x", Descending: true}, "ba"},
+		{Filter{ToAddress: "x", After: &Cursor{Timestamp: now, TxID: "a"}}, "b"},
+		{Filter{ToAddress: "w"}, ""},
+	}
+
+	for _, test := range tests {
+		test.filter.Limit = 25
+		results, err := r.GetTransactions(test.filter)
+
+		txIDs := ""
+		for _, result := range results {
+			txIDs += result.TxID
+		}
+
+		if txIDs != test.txIDs || err != nil {
+			t.Error("Filter does not match:", test.filter, txIDs, test.txIDs, err)
+		}
+	}
+
+	if results, err := r.GetTransactions(Filter{ToAddress: "x", Descending: true, Limit: 1}); len(results) != 1 || results[0].TxID != "b" || err != nil {
+		t.Error("Limit of the address filter failed:", results, err)
+	}
+}
+
+func testGetTransactionIsCopy(t *testing.T, r Repository) {
+	r.SaveTransactions([]model.Transaction{spend("a", time.Now(), nil, 10)})
+
+	result, _ := r.GetTransaction("a")
+	result.Outputs[0].Value = 0
+
+	if result, _ := r.GetTransaction("a"); result.Outputs[0].Value != 10 {
+		t.Error("Stored transaction was modified:", result)
+	}
+}
+
+func testSaveTransactionsBatch(t *testing.T, r Repository) {
+	batch := []model.Transaction{
+		spend("a", time.Now(), nil, 10),
+		spend("b", time.Now(), []model.Outpoint{outpoint("a", 0)}, 6, 4),
+		spend("c", time.Now(), []model.Outpoint{outpoint("b", 1)}, 4),
+	}
+
+	err := r.SaveTransactions(batch)
+
+	lookup, _ := r.LookupOutputs([]model.Outpoint{outpoint("a", 0), outpoint("b", 0), outpoint("b", 1), outpoint("c", 0)})
+	if len(lookup.Spent) != 2 || len(lookup.Unspent) != 2 || err != nil {
+		t.Error("Outputs not spent within batch:", lookup, err)
+	}
+}
+
+func testSaveTransactionsDoubleSpend(t *testing.T, r Repository) {
+	r.SaveTransactions([]model.Transaction{spend("a", time.Now(), nil, 10, 5)})
+
+	var wg sync.WaitGroup
+	var succeeded int32
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Only the first output is spent by every transaction
+			outpoints := []model.Outpoint{outpoint("a", 0), outpoint("a", 1)}[:1+i%2]
+			err := r.SaveTransactions([]model.Transaction{spend("b"+strconv.Itoa(i), time.Now(), outpoints, 10)})
+			if err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			} else if !errors.Is(err, ErrAlreadySpent) {
+				t.Error("ErrAlreadySpent not returned:", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Error("Expected exactly one spend to succeed:", succeeded)
+	}
+}
+
+func testSaveTransactionsRejected(t *testing.T, r Repository) {
+	now := time.Now()
+	r.SaveTransactions([]model.Transaction{spend("a", now, nil, 10, 5)})
+
+	for _, c := range []struct {
+		name  string
+		batch []model.Transaction
+		err   error
+	}{
+		{"output spent twice in the batch", []model.Transaction{
+			spend("b", now, []model.Outpoint{outpoint("a", 0)}, 10),
+			spend("c", now, []model.Outpoint{outpoint("a", 0)}, 10),
+		}, ErrAlreadySpent},
+		{"output spent twice by a transaction", []model.Transaction{spend("b", now, []model.Outpoint{outpoint("a", 1), outpoint("a", 1)}, 10)}, ErrAlreadySpent},
+		{"missing transaction", []model.Transaction{spend("b", now, []model.Outpoint{outpoint("x", 0)}, 10)}, ErrMissingOutput},
+		{"missing output", []model.Transaction{spend("b", now, []model.Outpoint{outpoint("a", 2)}, 10)}, ErrMissingOutput},
+		{"missing output in the batch", []model.Transaction{
+			spend("b", now, []model.Outpoint{outpoint("a", 0)}, 10),
+			spend("c", now, []model.Outpoint{outpoint("b", 1)}, 10),
+		}, ErrMissingOutput},
+		{"saved transaction", []model.Transaction{spend("b", now, nil, 1), spend("a", now, nil, 1)}, ErrTransactionExists},
+		{"transaction twice in the batch", []model.Transaction{spend("b", now, nil, 1), spend("b", now, nil, 1)}, ErrTransactionExists},
+	} {
+		err := r.SaveTransactions(c.batch)
+
+		if _, ok := err.(*CommitError); !ok || !errors.Is(err, c.err) {
+			t.Error(c.name, "not rejected:", err)
+		}
+
+		if result, err := r.GetTransaction("b"); result != nil || err != nil {
+			t.Error(c.name, "not rolled back:", result, err)
+		}
+	}
+
+	if result, _ := r.GetTransaction("a"); result == nil || result.OutputValue() != 15 {
+		t.Error("Saved transaction was overwritten:", result)
+	}
+
+	if lookup, err := r.LookupOutputs([]model.Outpoint{outpoint("a", 0), outpoint("a", 1)}); len(lookup.Unspent) != 2 || err != nil {
+		t.Error("Outputs spent by rejected batches:", lookup, err)
+	}
+
+	if size, err := r.LogSize(); size != 1 || err != nil {
+		t.Error("Rejected batches appended to the log:", size, err)
+	}
+}
+
+func testLookupOutputs(t *testing.T, r Repository) {
+	r.SaveTransactions([]model.Transaction{spend("a", time.Now(), nil, 10, 5)})
+	r.SaveTransactions([]model.Transaction{spend("b", time.Now(), []model.Outpoint{outpoint("a", 0)}, 10)})
+
+	lookup, err := r.LookupOutputs([]model.Outpoint{outpoint("a", 0), outpoint("a", 1), outpoint("b", 0), outpoint("a", 2), outpoint("c", 0), outpoint("a", 1)})
+
+	if err != nil || len(lookup.Unspent) != 2 || lookup.Unspent[outpoint("a", 1)] == nil || lookup.Unspent[outpoint("b", 0)].OutputValue() != 10 {
+		t.Error("Unspent outputs do not match:", lookup, err)
+	}
+
+	if len(lookup.Spent) != 1 || lookup.Spent[0] != outpoint("a", 0) {
+		t.Error("Spent outputs do not match:", lookup.Spent)
+	}
+
+	if len(lookup.Missing) != 2 || lookup.Missing[0] != outpoint("a", 2) || lookup.Missing[1] != outpoint("c", 0) {
+		t.Error("Missing outputs do not match:", lookup.Missing)
+	}
+}
+
+func testGetHistory(t *testing.T, r Repository) {
+	now := time.Now()
+	r.SaveTransactions([]model.Transaction{spend("a", now, nil, 10, 5)})
+	r.SaveTransactions([]model.Transaction{spend("b", now.Add(time.Second), []model.Outpoint{outpoint("a", 0)}, 10)})
+	r.SaveTransactions([]model.Transaction{spend("c", now.Add(2*time.Second), []model.Outpoint{outpoint("b", 0), outpoint("a", 1)}, 15)})
+
+	// a is an input of c and of b, it is only returned once
+	results, err := r.GetHistory("c", -1, 0, 25)
+
+	txIDs := ""
+	for _, result := range results {
+		txIDs += result.TxID
+	}
+
+	if txIDs != "cab" || err != nil {
+		t.Error("History does not match:", txIDs, err)
+	}
+
+	if results, err := r.GetHistory("b", 0, 0, 25); len(results) != 1 || err != nil {
+		t.Error("Depth not applied:", results, err)
+	}
+
+	if results, err := r.GetHistory("c", -1, 1, 1); len(results) != 1 || results[0].TxID != "a" || err != nil {
+		t.Error("Page does not match:", results, err)
+	}
+
+	if results, err := r.GetHistory("d", -1, 0, 25); len(results) != 0 || err != nil {
+		t.Error("Unknown transaction has history:", results, err)
+	}
+}
+
+func testGetDescendants(t *testing.T, r Repository) {
+	now := time.Now()
+	r.SaveTransactions([]model.Transaction{spend("a", now, nil, 6, 4)})
+	r.SaveTransactions([]model.Transaction{
+		spend("c", now.Add(time.Second), []model.Outpoint{outpoint("a", 0)}, 6),
+		spend("b", now.Add(time.Second), []model.Outpoint{outpoint("a", 1)}, 4),
+	})
+	r.SaveTransactions([]model.Transaction{spend("d", now.Add(2*time.Second), []model.Outpoint{outpoint("c", 0)}, 6)})
+
+	results, err := r.GetDescendants("a", -1, 0, 25)
+
+	txIDs := ""
+	for _, result := range results {
+		txIDs += result.TxID
+	}
+
+	if txIDs != "abcd" || err != nil {
+		t.Error("Descendants do not match:", txIDs, err)
+	}
+
+	if results, err := r.GetDescendants("a", 1, 0, 25); len(results) != 3 || err != nil {
+		t.Error("Depth not applied:", results, err)
+	}
+
+	if results, err := r.GetDescendants("a", -1, 3, 25); len(results) != 1 || results[0].TxID != "d" || err != nil {
+		t.Error("Page does not match:", results, err)
+	}
+}
+
+func testTimestampRoundTrip(t *testing.T, r Repository) {
+	timestamp := time.Unix(1500000000, 123456789).In(time.FixedZone("UTC+2", 2*60*60))
+	transaction := spend("a", timestamp, nil, 10)
+	hash, _ := transaction.Hash()
+
+	r.SaveTransactions([]model.Transaction{transaction})
+	result, _ := r.GetTransaction("a")
+	resultHash, _ := result.Hash()
+
+	if !result.Timestamp.Equal(timestamp) || result.Timestamp.Location() != time.UTC || string(hash) != string(resultHash) {
+		t.Error("Timestamp does not match:", result.Timestamp, timestamp)
+	}
+}
+
+func testSaveBlock(t *testing.T, r Repository) {
+	if last, err := r.LastBlock(); last != nil || err != nil {
+		t.Error("Last block of an empty ledger found:", last, err)
+	}
+
+	first := model.NewBlock(nil, []string{"a"})
+	if err := r.SaveBlock(first, []model.Transaction{spend("a", time.Now(), nil, 10)}); err != nil {
+		t.Fatal("SaveBlock failed:", err)
+	}
+
+	if result, err := r.GetTransaction("a"); result == nil || err != nil {
+		t.Error("Transaction of the block not saved:", result, err)
+	}
+
+	// A block which does not meet its proof of work target is not saved
+	unmined := model.NewBlock(first, []string{"b"})
+	unmined.Header.Target = model.TargetFromBits(64)
+	unmined.Hash = unmined.Header.Hash()
+	if err := r.SaveBlock(unmined, []model.Transaction{spend("b", time.Now(), nil, 10)}); !errors.Is(err, ErrInvalidBlock) {
+		t.Error("ErrInvalidBlock not returned:", err)
+	}
+
+	// A second block at the same height conflicts, its transactions are rolled back
+	other := model.NewBlock(nil, []string{"b"})
+	if err := r.SaveBlock(other, []model.Transaction{spend("b", time.Now(), nil, 10)}); !errors.Is(err, ErrBlockConflict) {
+		t.Error("ErrBlockConflict not returned:", err)
+	}
+
+	if result, err := r.GetTransaction("b"); result != nil || err != nil {
+		t.Error("Transaction of the conflicting block saved:", result, err)
+	}
+
+	second := model.NewBlock(first, []string{"b"})
+	if err := r.SaveBlock(second, []model.Transaction{spend("b", time.Now(), []model.Outpoint{outpoint("a", 0)}, 10)}); err != nil {
+		t.Fatal("SaveBlock failed:", err)
+	}
+
+	last, err := r.LastBlock()
+	if last == nil || last.Hash != second.Hash || !last.Valid() || !last.Header.Timestamp.Equal(second.Header.Timestamp) || err != nil {
+		t.Error("Last block does not match:", last, err)
+	}
+
+	if result, err := r.GetBlock(0); result == nil || result.Hash != first.Hash || len(result.TxIDs) != 1 || err != nil {
+		t.Error("Block by height does not match:", result, err)
+	}
+
+	if result, err := r.GetBlockByHash(second.Hash); result == nil || result.Header.Height != 1 || err != nil {
+		t.Error("Block by hash does not match:", result, err)
+	}
+
+	if result, err := r.GetBlockByTxID("b"); result == nil || result.Hash != second.Hash || err != nil {
+		t.Error("Block by TxID does not match:", result, err)
+	}
+
+	if result, err := r.GetBlock(2); result != nil || err != nil {
+		t.Error("Unknown block found:", result, err)
+	}
+
+	if result, err := r.GetBlockByTxID("c"); result != nil || err != nil {
+		t.Error("Block of an unknown transaction found:", result, err)
+	}
+
+	if result, err := r.GetBlockByHash(other.Hash); result != nil || err != nil {
+		t.Error("Conflicting block found:", result, err)
+	}
+}
+
+// mine sets the target of the block and searches its nonce.
+func mine(block *model.Block, target string) *model.Block {
+	block.Header.Target = target
+	for !block.Header.MeetsTarget() {
+		block.Header.Nonce++
+	}
+	block.Hash = block.Header.Hash()
+
+	return block
+}
+
+func testSaveBlockTarget(t *testing.T, r Repository) {
+	first := model.NewBlock(nil, []string{"a"})
+	if err := r.SaveBlock(first, []model.Transaction{spend("a", time.Now(), nil, 10)}); err != nil {
+		t.Fatal("SaveBlock failed:", err)
+	}
+
+	second := mine(model.NewBlock(first, []string{"b"}), model.TargetFromBits(4))
+	if err := r.SaveBlock(second, []model.Transaction{spend("b", time.Now(), nil, 10)}); err != nil {
+		t.Fatal("SaveBlock failed:", err)
+	}
+
+	// Once proof of work started, a block without a target or with a target more than 4 times easier
+	// is not saved
+	for _, c := range []struct {
+		name  string
+		block *model.Block
+	}{
+		{"no target", model.NewBlock(second, []string{"c"})},
+		{"target 8 times easier", mine(model.NewBlock(second, []string{"c"}), model.TargetFromBits(1))},
+	} {
+		if err := r.SaveBlock(c.block, []model.Transaction{spend("c", time.Now(), nil, 10)}); !errors.Is(err, ErrInvalidBlock) {
+			t.Error("ErrInvalidBlock not returned:", c.name, err)
+		}
+	}
+
+	if result, err := r.GetTransaction("c"); result != nil || err != nil {
+		t.Error("Transaction of the invalid block saved:", result, err)
+	}
+
+	third := mine(model.NewBlock(second, []string{"c"}), model.TargetFromBits(3))
+	if err := r.SaveBlock(third, []model.Transaction{spend("c", time.Now(), nil, 10)}); err != nil {
+		t.Error("Block with a target 2 times easier not saved:", err)
+	}
+}
+
+func testGetBlocks(t *testing.T, r Repository) {
+	var previous *model.Block
+	for i := 0; i < 5; i++ {
+		txID := strconv.Itoa(i)
+		previous = model.NewBlock(previous, []string{txID})
+		r.SaveBlock(previous, []model.Transaction{spend(txID, time.Now(), nil, 10)})
+	}
+
+	heights := func(blocks []model.Block) string {
+		var result string
+		for _, b := range blocks {
+			result += strconv.FormatInt(b.Header.Height, 10)
+		}
+		return result
+	}
+
+	for _, c := range []struct {
+		from       int64
+		limit      int
+		descending bool
+		heights    string
+	}{
+		{0, 25, false, "01234"},
+		{-1, 2, false, "01"},
+		{3, 25, false, "34"},
+		{5, 25, false, ""},
+		{-1, 25, true, "43210"},
+		{2, 25, true, "210"},
+		{9, 2, true, "43"},
+	} {
+		blocks, err := r.GetBlocks(c.from, c.limit, c.descending)
+		if heights(blocks) != c.heights || err != nil {
+			t.Error("Blocks do not match:", c.from, c.limit, c.descending, heights(blocks), err)
+		}
+	}
+}
+
+func testLog(t *testing.T, r Repository) {
+	if size, err := r.LogSize(); size != 0 || err != nil {
+		t.Error("Log of an empty ledger not empty:", size, err)
+	}
+
+	r.SaveTransactions([]model.Transaction{spend("a", time.Now(), nil, 10), spend("b", time.Now(), nil, 10)})
+	r.SaveBlock(model.NewBlock(nil, []string{"c"}), []model.Transaction{spend("c", time.Now(), []model.Outpoint{outpoint("a", 0)}, 10)})
+
+	// A rejected batch is not appended
+	r.SaveTransactions([]model.Transaction{spend("d", time.Now(), []model.Outpoint{outpoint("a", 0)}, 10)})
+
+	if size, err := r.LogSize(); size != 3 || err != nil {
+		t.Error("Log size does not match:", size, err)
+	}
+
+	for _, c := range []struct {
+		start, end int64
+		entries    string
+	}{
+		{0, 3, "abc"},
+		{1, 9, "bc"},
+		{2, 2, ""},
+		{3, 5, ""},
+	} {
+		entries, err := r.GetLogEntries(c.start, c.end)
+		if strings.Join(entries, "") != c.entries || err != nil {
+			t.Error("Log entries do not match:", c.start, c.end, entries, err)
+		}
+	}
+
+	if index, err := r.GetLogIndex("c"); index != 2 || err != nil {
+		t.Error("Log index does not match:", index, err)
+	}
+
+	if index, err := r.GetLogIndex("d"); index != -1 || err != nil {
+		t.Error("Log index of an unknown transaction found:", index, err)
+	}
+}
+
+func testQuarantine(t *testing.T, r Repository) {
+	r.SaveTransactions([]model.Transaction{spend("a", time.Now(), nil, 10), spend("b", time.Now(), nil, 10)})
+
+	if err := r.Quarantine([]string{"a"}); err != nil {
+		t.Fatal("Quarantine failed:", err)
+	}
+
+	lookup, err := r.LookupOutputs([]model.Outpoint{outpoint("a", 0), outpoint("b", 0)})
+	if len(lookup.Missing) != 1 || lookup.Missing[0] != outpoint("a", 0) || len(lookup.Unspent) != 1 || err != nil {
+		t.Error("Outputs of a quarantined transaction not missing:", lookup, err)
+	}
+
+	if result, err := r.GetTransaction("a"); result == nil || err != nil {
+		t.Error("Quarantined transaction not kept:", result, err)
+	}
+}
+
+func testAcceptance(t *testing.T, r Repository) {
+	accepted := spend("a", time.Now(), nil, 10)
+	accepted.Acceptance = &model.Acceptance{AcceptedAt: time.Unix(0, 1500000000123456789).UTC(), Sequence: 7}
+	r.SaveTransactions([]model.Transaction{accepted, spend("b", time.Now(), nil, 10)})
+
+	if result, err := r.GetTransaction("a"); result == nil || result.Acceptance == nil || *result.Acceptance != *accepted.Acceptance || err != nil {
+		t.Error("Acceptance not saved:", result, err)
+	}
+
+	if result, err := r.GetTransaction("b"); result == nil || result.Acceptance != nil || err != nil {
+		t.Error("Acceptance of a transaction without one does not match:", result, err)
+	}
+
+	first, err := r.ReserveSequence(10)
+	if first != 10 || err != nil {
+		t.Error("ReserveSequence failed:", first, err)
+	}
+
+	if last, err := r.ReserveSequence(5); last != 15 || err != nil {
+		t.Error("Reserved sequence numbers do not follow:", last, err)
+	}
+}
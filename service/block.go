@@ -0,0 +1,41 @@
+package service
+
+import "cryptocoin-server/model"
+
+// GetBlocks returns at most limit blocks from the height, ordered by height. Descending blocks start at the
+// last block if from is negative.
+func GetBlocks(from int64, limit int, descending bool) ([]model.Block, error) {
+	blocks, err := repo.GetBlocks(from, limit, descending)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if blocks == nil {
+		blocks = []model.Block{}
+	}
+
+	return blocks, nil
+}
+
+// GetBlock returns the block at the height, or ErrBlockNotFound.
+func GetBlock(height int64) (*model.Block, error) {
+	return foundBlock(repo.GetBlock(height))
+}
+
+// GetBlockByHash returns the block by hash, or ErrBlockNotFound.
+func GetBlockByHash(hash string) (*model.Block, error) {
+	return foundBlock(repo.GetBlockByHash(hash))
+}
+
+// foundBlock returns the result of a repository block lookup, with ErrBlockNotFound for a missing block.
+func foundBlock(block *model.Block, err error) (*model.Block, error) {
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if block == nil {
+		return nil, ErrBlockNotFound
+	}
+
+	return block, nil
+}
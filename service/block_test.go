@@ -0,0 +1,55 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"testing"
+)
+
+func TestConfirmPendingBlocks(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	// Every transaction is confirmed in its own block, the genesis transaction in the first block
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	second, _ := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+
+	blocks, err := GetBlocks(0, 25, false)
+	if len(blocks) != 3 || err != nil {
+		t.Fatal("Blocks do not match:", blocks, err)
+	}
+
+	if !blocks[0].Valid() || !blocks[0].Follows(nil) || blocks[0].TxIDs[0] != genesis.TxID {
+		t.Error("Genesis block does not match:", blocks[0])
+	}
+
+	if !blocks[1].Valid() || !blocks[1].Follows(&blocks[0]) || blocks[1].TxIDs[0] != first.TxID {
+		t.Error("First block does not match:", blocks[1])
+	}
+
+	if !blocks[2].Valid() || !blocks[2].Follows(&blocks[1]) || blocks[2].TxIDs[0] != second.TxID {
+		t.Error("Second block does not match:", blocks[2])
+	}
+
+	if block, err := GetBlock(1); block == nil || block.Hash != blocks[1].Hash || err != nil {
+		t.Error("Block by height does not match:", block, err)
+	}
+
+	if block, err := GetBlockByHash(blocks[0].Hash); block == nil || block.Header.Height != 0 || err != nil {
+		t.Error("Block by hash does not match:", block, err)
+	}
+
+	if _, err := GetBlock(3); err != ErrBlockNotFound {
+		t.Error("ErrBlockNotFound not returned:", err)
+	}
+
+	if _, err := GetBlockByHash(model.ZeroHash); err != ErrBlockNotFound {
+		t.Error("ErrBlockNotFound not returned:", err)
+	}
+
+	// Nothing is pending, no block is sealed
+	if block, err := ConfirmPending(); block != nil || err != nil {
+		t.Error("Empty block was sealed:", block, err)
+	}
+}
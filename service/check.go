@@ -0,0 +1,32 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+)
+
+// CheckTimestamps returns the transactions of the ledger whose signature does not verify with the
+// stored timestamp. Transactions saved before timestamps were stored in nanoseconds lost the fraction
+// of the second, so their hash no longer matches the signed hash.
+func CheckTimestamps() ([]model.Transaction, error) {
+	var affected []model.Transaction
+	filter := repository.Filter{Limit: 100}
+
+	for {
+		transactions, err := repo.GetTransactions(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range transactions {
+			if verified, _ := VerifySignature(&t); !verified {
+				affected = append(affected, t)
+			}
+		}
+
+		if len(transactions) < filter.Limit {
+			return affected, nil
+		}
+		filter.After = repository.NewCursor(&transactions[len(transactions)-1])
+	}
+}
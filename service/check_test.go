@@ -0,0 +1,130 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"testing"
+	"time"
+)
+
+// previous returns the transaction x, with an output of 100 to the wallet.
+func previous(wallet *model.Wallet) model.Transaction {
+	return model.Transaction{TxID: "x", Timestamp: time.Unix(1400000000, 0), Outputs: []model.Output{{ToAddress: wallet.PubKey, Value: 100}}}
+}
+
+func TestCheckTimestamps(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	InitService(r)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+
+	// A transaction read back with the timestamp truncated to seconds
+	truncated := model.Transaction{
+		Timestamp: time.Unix(1500000000, 500),
+		Inputs:    []model.Input{{Outpoint: model.Outpoint{TxID: "x"}, PubKey: wallet.PubKey}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: 5}},
+	}
+	SignInputs(&truncated, wallet.PrivKey)
+	truncated.TxID, _ = truncated.ID()
+	truncated.Timestamp = time.Unix(1500000000, 0)
+	r.SaveTransactions([]model.Transaction{previous(wallet), truncated})
+
+	affected, err := CheckTimestamps()
+
+	if len(affected) != 1 || affected[0].TxID != truncated.TxID || err != nil {
+		t.Error("Affected transactions do not match:", affected, err)
+	}
+}
+
+func TestSignatureStorageRoundTrip(t *testing.T) {
+	r, err := repository.NewBBoltRepository(t.TempDir() + "/ledger.db")
+	if err != nil {
+		t.Fatal("NewBBoltRepository failed:", err)
+	}
+	defer r.Close()
+	InitService(r)
+
+	wallet, _ := model.NewWallet()
+	transaction := model.Transaction{
+		Timestamp: time.Now().In(time.FixedZone("UTC-5", -5*60*60)),
+		Inputs:    []model.Input{{Outpoint: model.Outpoint{TxID: "x"}, PubKey: wallet.PubKey}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: 5}},
+	}
+	SignInputs(&transaction, wallet.PrivKey)
+	transaction.TxID, _ = transaction.ID()
+	r.SaveTransactions([]model.Transaction{previous(wallet), transaction})
+
+	stored, _ := GetTransaction(transaction.TxID)
+
+	if verified, err := VerifySignature(stored); !verified || err != nil || !stored.Timestamp.Equal(transaction.Timestamp) || stored.Timestamp.Location() != time.UTC {
+		t.Error("Signature does not verify after storage round trip:", stored, err)
+	}
+}
+
+func TestSpendLegacyRecord(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	InitService(r)
+	owner, _ := model.NewWallet()
+	receiver, _ := model.NewWallet()
+	key, _ := ecdsa.ParsePrivKey(owner.PrivKey)
+
+	// A record signed with the legacy hash in another time zone, stored in UTC and in seconds
+	record := model.Record{
+		TxID:      "legacy",
+		Timestamp: time.Date(2018, 5, 1, 14, 0, 0, 123456789, time.FixedZone("UTC+2", 2*60*60)),
+		ToAddress: owner.PubKey,
+		Value:     100,
+		PubKey:    owner.PubKey,
+		PrevTxID:  "x",
+	}
+	hash, _ := record.LegacyHash()
+	record.Signature, _ = ecdsa.Sign(key, hash)
+	record.Timestamp = record.Timestamp.Truncate(time.Second).UTC()
+	legacy := record.Transaction()
+	legacy.TimestampInSeconds = true
+	r.SaveTransactions([]model.Transaction{previous(owner), legacy})
+
+	nt := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: model.Outpoint{TxID: "legacy"}, PubKey: owner.PubKey}},
+		Outputs:   []model.Output{{ToAddress: receiver.PubKey, Value: 100}},
+	}
+	SignInputs(&nt, owner.PrivKey)
+
+	if err := AddTransactions([]model.Transaction{nt}); err != nil {
+		t.Error("Spending a legacy record failed:", err)
+	}
+}
+
+func TestSpendRecordNotInSeconds(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	InitService(r)
+	owner, _ := model.NewWallet()
+	receiver, _ := model.NewWallet()
+
+	// A record with a whole second timestamp whose signature does not verify, which was not saved in seconds
+	record := model.Record{
+		TxID:      "tampered",
+		Timestamp: time.Date(2018, 5, 1, 14, 0, 0, 0, time.UTC),
+		ToAddress: owner.PubKey,
+		Value:     100,
+		PubKey:    owner.PubKey,
+		PrevTxID:  "x",
+		Signature: "AAAA",
+	}
+	r.SaveTransactions([]model.Transaction{previous(owner), record.Transaction()})
+
+	nt := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: model.Outpoint{TxID: "tampered"}, PubKey: owner.PubKey}},
+		Outputs:   []model.Output{{ToAddress: receiver.PubKey, Value: 100}},
+	}
+	SignInputs(&nt, owner.PrivKey)
+
+	if err := AddTransactions([]model.Transaction{nt}); ErrorCode(err) != CodeInvalidPreviousSignature {
+		t.Error("Spending a record whose signature does not verify not rejected:", err)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"cryptocoin-server/repository"
+	"errors"
+	"fmt"
+)
+
+// Codes of the errors which are not consensus rules, see ErrorCode.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeNotFound             = "not_found"
+	CodeAlreadySpent         = "already_spent"
+	CodeStorageUnavailable   = "storage_unavailable"
+	CodeTxIDMismatch         = "txid_mismatch"
+	CodeDuplicateTransaction = "duplicate_transaction"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeNotInBlock           = "not_in_block"
+	CodeNotInLog             = "not_in_log"
+	CodeMempoolFull          = "mempool_full"
+)
+
+// ErrNotFound is returned when a transaction does not exist.
+var ErrNotFound = errors.New("Transaction not found")
+
+// ErrBlockNotFound is returned when a block does not exist.
+var ErrBlockNotFound = errors.New("Block not found")
+
+// ErrEmptyBatch is returned when a batch has no transactions.
+var ErrEmptyBatch = errors.New("Batch must have transactions")
+
+// ErrStorageUnavailable is returned when the repository fails.
+var ErrStorageUnavailable = errors.New("Storage is unavailable")
+
+// BatchError is returned when a transaction of a batch is rejected. Index is the position of the
+// transaction in the batch.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("Transaction %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the transaction.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the machine-readable code of an error of the service: the code of the failed rule,
+// or of the typed error. Other errors are invalid requests.
+func ErrorCode(err error) string {
+	var ruleErr *RuleError
+
+	switch {
+	case errors.As(err, &ruleErr):
+		return ruleErr.Code
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrBlockNotFound):
+		return CodeNotFound
+	case errors.Is(err, repository.ErrAlreadySpent):
+		return CodeAlreadySpent
+	case errors.Is(err, ErrStorageUnavailable):
+		return CodeStorageUnavailable
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		return CodeIdempotencyKeyReused
+	case errors.Is(err, ErrNotInBlock):
+		return CodeNotInBlock
+	case errors.Is(err, ErrNotInLog):
+		return CodeNotInLog
+	case errors.Is(err, ErrMempoolFull):
+		return CodeMempoolFull
+	}
+
+	return CodeInvalidRequest
+}
+
+// storageError wraps an error of the repository with ErrStorageUnavailable. An output spent by a
+// concurrent batch is not a failure of the repository.
+func storageError(err error) error {
+	if err == nil || errors.Is(err, repository.ErrAlreadySpent) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingRepository fails every lookup, like a repository which lost the connection to the database.
+type failingRepository struct {
+	repository.Repository
+}
+
+func (r failingRepository) GetTransaction(txID string) (*model.Transaction, error) {
+	return nil, errors.New("Connection refused")
+}
+
+func (r failingRepository) LookupOutputs(outpoints []model.Outpoint) (*repository.Lookup, error) {
+	return nil, errors.New("Connection refused")
+}
+
+func (r failingRepository) LogSize() (int64, error) {
+	return 0, errors.New("Connection refused")
+}
+
+func TestAddTransactionsBatchError(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+
+	// The second transaction of the batch is not signed
+	valid := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: first.Outpoint(1), PubKey: first.Outputs[1].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: first.Outputs[1].Value}},
+	}
+	SignInputs(&valid, config.InitConfig().GenesisPrivKey)
+
+	unsigned := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: first.Outpoint(0), PubKey: wallet.PubKey}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: 100}},
+	}
+
+	err := AddTransactions([]model.Transaction{valid, unsigned})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || ErrorCode(err) != CodeInvalidSignature {
+		t.Error("Batch error does not match:", err, ErrorCode(err))
+	}
+
+	// The second transaction spends an output already spent in the ledger
+	respend := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesis.Outputs[0].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: 100}},
+	}
+	SignInputs(&respend, config.InitConfig().GenesisPrivKey)
+
+	err = AddTransactions([]model.Transaction{valid, respend})
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || ErrorCode(err) != CodeAlreadySpent {
+		t.Error("Batch error does not match:", err, ErrorCode(err))
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+
+	if _, err := GetTransaction("unknown"); err != ErrNotFound || ErrorCode(err) != CodeNotFound {
+		t.Error("ErrNotFound not returned:", err)
+	}
+
+	if code := ErrorCode(errors.New("Parameter limit must be an integer")); code != CodeInvalidRequest {
+		t.Error("Untyped error code does not match:", code)
+	}
+
+	InitService(failingRepository{})
+	defer InitService(repository.NewMemoryRepository())
+
+	if _, err := GetTransaction("unknown"); !errors.Is(err, ErrStorageUnavailable) || ErrorCode(err) != CodeStorageUnavailable {
+		t.Error("ErrStorageUnavailable not returned:", err)
+	}
+
+	if err := AddTransactions([]model.Transaction{{Timestamp: time.Now(), Inputs: []model.Input{{Outpoint: model.Outpoint{TxID: "a"}}}}}); ErrorCode(err) != CodeStorageUnavailable {
+		t.Error("ErrStorageUnavailable not returned:", err)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"errors"
+	"time"
+)
+
+// FeePolicy is the minimum fee of transactions and the address the fees are sent to.
+type FeePolicy struct {
+	PerByte         int64 // For each byte of the canonical encoding
+	PerInput        int64
+	OperatorAddress string
+}
+
+var feePolicy = NewFeePolicy(config.InitConfig())
+
+// NewFeePolicy creates the fee policy of the config.
+func NewFeePolicy(config *config.Config) *FeePolicy {
+	p := new(FeePolicy)
+	p.PerByte = config.FeePerByte
+	p.PerInput = config.FeePerInput
+	p.OperatorAddress = config.OperatorAddress
+	return p
+}
+
+// MinimumFee returns the minimum fee of a transaction with the size and number of inputs.
+func (p *FeePolicy) MinimumFee(size int, inputs int) int64 {
+	return p.PerByte*int64(size) + p.PerInput*int64(inputs)
+}
+
+// EstimateFee returns the minimum fee of a typical transaction with the number of inputs and outputs.
+func EstimateFee(inputs int, outputs int) (*model.FeeEstimate, error) {
+	if inputs < 1 || outputs < 1 {
+		return nil, errors.New("Transaction must have inputs and outputs")
+	}
+
+	estimate := new(model.FeeEstimate)
+	estimate.Inputs = inputs
+	estimate.Outputs = outputs
+	estimate.Size = model.EstimateSize(inputs, outputs)
+	estimate.FeePerByte = feePolicy.PerByte
+	estimate.FeePerInput = feePolicy.PerInput
+	estimate.Fee = feePolicy.MinimumFee(estimate.Size, inputs)
+	estimate.OperatorAddress = feePolicy.OperatorAddress
+
+	return estimate, nil
+}
+
+// Fee returns the fee of the transaction: the total value of the inputs minus the total value of the
+// outputs. prevTransactions are the transactions of the outputs spent by the inputs.
+func Fee(t *model.Transaction, prevTransactions map[model.Outpoint]*model.Transaction) int64 {
+	var inputValue int64
+	for _, input := range t.Inputs {
+		if pt := prevTransactions[input.Outpoint]; pt != nil && pt.Output(input.Outpoint) != nil {
+			inputValue += pt.Output(input.Outpoint).Value
+		}
+	}
+
+	return inputValue - t.OutputValue()
+}
+
+// collectFees creates the fee transaction sending the fees of the transactions to the operator, or
+// returns nil if the transactions do not pay fees.
+func collectFees(transactions []model.Transaction, prevTransactions map[model.Outpoint]*model.Transaction) (*model.Transaction, error) {
+	fee := model.NewTransaction()
+
+	var total int64
+	for i := range transactions {
+		if value := Fee(&transactions[i], prevTransactions); value > 0 {
+			var err error
+			if total, err = addValue(total, value); err != nil {
+				return nil, err
+			}
+			fee.Collects = append(fee.Collects, transactions[i].TxID)
+		}
+	}
+
+	if total == 0 {
+		return nil, nil
+	}
+
+	fee.Timestamp = time.Now()
+	fee.Outputs = []model.Output{{ToAddress: feePolicy.OperatorAddress, Value: total}}
+
+	txID, err := fee.ID()
+	if err != nil {
+		return nil, err
+	}
+
+	fee.TxID = txID
+
+	return fee, nil
+}
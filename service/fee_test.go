@@ -0,0 +1,89 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"testing"
+	"time"
+)
+
+// setFeePolicy sets the fee policy for a test and returns a function restoring the previous one.
+func setFeePolicy(perByte int64, perInput int64) func() {
+	previous := feePolicy
+	feePolicy = &FeePolicy{PerByte: perByte, PerInput: perInput, OperatorAddress: previous.OperatorAddress}
+	return func() { feePolicy = previous }
+}
+
+func TestEstimateFee(t *testing.T) {
+	defer setFeePolicy(2, 100)()
+
+	estimate, err := EstimateFee(2, 3)
+
+	if estimate == nil || estimate.Size != model.EstimateSize(2, 3) || estimate.Fee != 2*int64(estimate.Size)+2*100 || err != nil {
+		t.Error("EstimateFee failed:", estimate, err)
+	}
+
+	if _, err := EstimateFee(0, 1); err == nil {
+		t.Error("Estimate without inputs was accepted")
+	}
+}
+
+func TestAddTransactionsFee(t *testing.T) {
+	defer setFeePolicy(0, 100)()
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+
+	transaction, err := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 1000)
+	if err != nil {
+		t.Fatal("TransferFromGenesisAccount failed:", err)
+	}
+
+	if transaction.Outputs[1].Value != genesis.Outputs[0].Value-1000-100 {
+		t.Error("Change does not pay the fee:", transaction.Outputs)
+	}
+
+	// The fee is sent to the operator
+	transactions, _ := repo.GetTransactions(repository.Filter{Limit: 10})
+
+	var fee *model.Transaction
+	for i := range transactions {
+		if transactions[i].IsFee() {
+			fee = &transactions[i]
+		}
+	}
+
+	if fee == nil || len(fee.Collects) != 1 || fee.Collects[0] != transaction.TxID || fee.Outputs[0].Value != 100 || fee.Outputs[0].ToAddress != feePolicy.OperatorAddress {
+		t.Error("Fee transaction does not match:", fee)
+	}
+}
+
+func TestAddTransactionsMinimumFee(t *testing.T) {
+	defer setFeePolicy(1, 0)()
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+
+	nt := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesis.Outputs[0].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: genesis.Outputs[0].Value - 10}},
+	}
+	SignInputs(&nt, config.InitConfig().GenesisPrivKey)
+
+	if err := AddTransactions([]model.Transaction{nt}); err == nil {
+		t.Error("Transaction below the minimum fee was accepted")
+	}
+
+	// Fee transactions are only created by the server
+	nt.Outputs[0].Value = 0
+	nt.Collects = []string{genesis.TxID}
+	SignInputs(&nt, config.InitConfig().GenesisPrivKey)
+
+	if err := AddTransactions([]model.Transaction{nt}); err == nil {
+		t.Error("Transaction collecting fees was accepted")
+	}
+}
@@ -0,0 +1,6 @@
+package service
+
+// CheckHealth returns ErrStorageUnavailable if the repository does not answer.
+func CheckHealth() error {
+	return storageError(repo.Ping())
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"errors"
+	"strconv"
+)
+
+// GetTransactionHistory returns a page of the transaction and its previous transactions back to
+// GENESIS, at most depth steps away (Config.MaxWalkDepth if depth is negative or greater). The returned
+// cursor selects the next page and is empty on the last page.
+func GetTransactionHistory(txID string, depth int, cursor string, limit int) ([]model.Transaction, string, error) {
+	return walk(repo.GetHistory, txID, depth, cursor, limit)
+}
+
+// GetTransactionDescendants returns a page of the transaction and the transactions using it,
+// recursively, at most depth steps away like GetTransactionHistory.
+func GetTransactionDescendants(txID string, depth int, cursor string, limit int) ([]model.Transaction, string, error) {
+	return walk(repo.GetDescendants, txID, depth, cursor, limit)
+}
+
+// maxWalkDepth is the maximum depth of the walks.
+var maxWalkDepth = config.InitConfig().MaxWalkDepth
+
+// walk returns a page of a walk of the graph. The cursor is the number of transactions of the
+// previous pages.
+func walk(fn func(txID string, depth int, offset int, limit int) ([]model.Transaction, error), txID string, depth int, cursor string, limit int) ([]model.Transaction, string, error) {
+	offset := 0
+	if cursor != "" {
+		var err error
+		offset, err = strconv.Atoi(cursor)
+		if err != nil || offset < 0 {
+			return nil, "", errors.New("Invalid cursor")
+		}
+	}
+
+	if depth < 0 || depth > maxWalkDepth {
+		depth = maxWalkDepth
+	}
+
+	// Get one more transaction to know if there is a next page
+	transactions, err := fn(txID, depth, offset, limit+1)
+	if err != nil {
+		return nil, "", storageError(err)
+	}
+
+	// The walk always includes the transaction itself
+	if offset == 0 && len(transactions) == 0 {
+		return nil, "", ErrNotFound
+	}
+
+	if len(transactions) <= limit {
+		return transactions, "", nil
+	}
+
+	return transactions[:limit], strconv.Itoa(offset + limit), nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"testing"
+)
+
+func TestGetTransactionHistory(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	second, _ := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+
+	transactions, next, err := GetTransactionHistory(second.TxID, -1, "", 2)
+
+	if len(transactions) != 2 || transactions[0].TxID != second.TxID || transactions[1].TxID != first.TxID || next == "" || err != nil {
+		t.Fatal("First page does not match:", transactions, next, err)
+	}
+
+	transactions, next, err = GetTransactionHistory(second.TxID, -1, next, 2)
+
+	if len(transactions) != 1 || transactions[0].TxID != genesis.TxID || next != "" || err != nil {
+		t.Error("Last page does not match:", transactions, next, err)
+	}
+
+	transactions, _, _ = GetTransactionHistory(second.TxID, 1, "", 25)
+
+	if len(transactions) != 2 {
+		t.Error("Depth not applied:", transactions)
+	}
+
+	defer func(depth int) { maxWalkDepth = depth }(maxWalkDepth)
+	maxWalkDepth = 1
+
+	if transactions, _, _ := GetTransactionHistory(second.TxID, -1, "", 25); len(transactions) != 2 {
+		t.Error("Maximum depth not applied:", transactions)
+	}
+
+	if _, _, err := GetTransactionHistory("unknown", -1, "", 25); err != ErrNotFound {
+		t.Error("ErrNotFound not returned:", err)
+	}
+}
+
+func TestGetTransactionDescendants(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+
+	transactions, next, err := GetTransactionDescendants(genesis.TxID, -1, "", 25)
+
+	if len(transactions) != 3 || transactions[0].TxID != genesis.TxID || next != "" || err != nil {
+		t.Error("Descendants do not match:", transactions, next, err)
+	}
+
+	transactions, _, _ = GetTransactionDescendants(genesis.TxID, 1, "", 25)
+
+	if len(transactions) != 2 {
+		t.Error("Depth not applied:", transactions)
+	}
+
+	if _, _, err := GetTransactionDescendants(genesis.TxID, -1, "x", 25); err == nil {
+		t.Error("Invalid cursor accepted")
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"crypto/sha256"
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with another batch.
+var ErrIdempotencyKeyReused = errors.New("Idempotency key was used for another batch")
+
+// idempotencyStore keeps the result of the batches saved with an idempotency key for ttl.
+type idempotencyStore struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]idempotencyEntry
+	locks   *keyLocks // Only one request at a time may use a key
+}
+
+type idempotencyEntry struct {
+	fingerprint  string // Hash of the TxIDs of the batch
+	transactions []model.Transaction
+	receipts     []*model.Receipt
+	expires      time.Time
+}
+
+var idempotencyKeys = newIdempotencyStore(config.InitConfig().IdempotencyKeyTTL)
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	s := new(idempotencyStore)
+	s.ttl = ttl
+	s.entries = make(map[string]idempotencyEntry)
+	s.locks = newKeyLocks()
+	return s
+}
+
+// get returns the entry of the key, or false if the key is unknown or expired.
+func (s *idempotencyStore) get(key string) (idempotencyEntry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, contains := s.entries[key]
+	if !contains || time.Now().After(entry.expires) {
+		return idempotencyEntry{}, false
+	}
+
+	return entry, true
+}
+
+// put saves the result of the batch for the key, and removes the expired keys.
+func (s *idempotencyStore) put(key string, fingerprint string, transactions []model.Transaction, receipts []*model.Receipt) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	s.entries[key] = idempotencyEntry{fingerprint: fingerprint, transactions: transactions, receipts: receipts, expires: now.Add(s.ttl)}
+}
+
+// SubmitTransactions adds new transactions to the ledger like AddTransactions, but a repeated submission
+// returns the original result instead of an error. The result of a batch sent with an idempotency key is
+// kept for config.IdempotencyKeyTTL, sending the key again returns it. Without a key, a batch whose
+// transactions all exist in the ledger with the same TxIDs returns the saved transactions. It returns the
+// receipts of the transactions signed by the operator (see model.Receipt); a batch replayed without a key
+// has the receipts of the saved acceptance of the transactions, nil for the transactions saved without
+// one. The returned bool is true if the result is the one of a previous submission.
+func SubmitTransactions(key string, transactions []model.Transaction) ([]model.Transaction, []*model.Receipt, bool, error) {
+	fingerprint := batchFingerprint(transactions)
+
+	if key != "" {
+		unlock := idempotencyKeys.locks.Lock([]string{key})
+		defer unlock()
+
+		if entry, contains := idempotencyKeys.get(key); contains {
+			if entry.fingerprint != fingerprint {
+				return nil, nil, false, ErrIdempotencyKeyReused
+			}
+			return entry.transactions, entry.receipts, true, nil
+		}
+	}
+
+	replayed := false
+	accepted, err := addTransactions(transactions)
+	if err != nil {
+		// A retry of a batch which was saved
+		saved, existsErr := existingTransactions(transactions)
+		if existsErr != nil || saved == nil {
+			return nil, nil, false, err
+		}
+
+		transactions = saved
+		accepted = acceptanceReceipts(saved)
+		replayed = true
+	}
+
+	receipts := signReceipts(accepted)
+
+	if key != "" {
+		idempotencyKeys.put(key, fingerprint, transactions, receipts)
+	}
+
+	return transactions, receipts, replayed, nil
+}
+
+// batchFingerprint returns the hash of the TxIDs of the batch, or an empty string if a TxID can not be
+// calculated. Signatures are not part of the TxIDs, so a batch signed again has the same fingerprint.
+func batchFingerprint(transactions []model.Transaction) string {
+	hash := sha256.New()
+	for i := range transactions {
+		txID, err := transactions[i].ID()
+		if err != nil {
+			return ""
+		}
+		hash.Write([]byte(txID))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// existingTransactions returns the saved transactions if all the transactions of the batch are pending or
+// confirmed, or nil otherwise.
+func existingTransactions(transactions []model.Transaction) ([]model.Transaction, error) {
+	if len(transactions) == 0 {
+		return nil, nil
+	}
+
+	saved := make([]model.Transaction, len(transactions))
+	for i := range transactions {
+		txID, err := transactions[i].ID()
+		if err != nil {
+			return nil, nil
+		}
+
+		t, err := GetTransaction(txID)
+		if err == ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		saved[i] = *t
+	}
+
+	return saved, nil
+}
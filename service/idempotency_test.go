@@ -0,0 +1,101 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newPayment returns a signed transaction sending value from the output of the genesis transaction.
+func newPayment(genesis *model.Transaction, sendTo string, value int64) model.Transaction {
+	t := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesis.Outputs[0].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: sendTo, Value: value}, {ToAddress: genesis.Outputs[0].ToAddress, Value: genesis.Outputs[0].Value - value}},
+	}
+	SignInputs(&t, config.InitConfig().GenesisPrivKey)
+	return t
+}
+
+// setIdempotencyStore sets an empty store of idempotency keys for a test and returns a function restoring
+// the previous one.
+func setIdempotencyStore(ttl time.Duration) func() {
+	previous := idempotencyKeys
+	idempotencyKeys = newIdempotencyStore(ttl)
+	return func() { idempotencyKeys = previous }
+}
+
+func TestSubmitTransactionsIdempotencyKey(t *testing.T) {
+	defer setIdempotencyStore(time.Hour)()
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	payment := newPayment(genesis, wallet.PubKey, 100)
+
+	saved, _, replayed, err := SubmitTransactions("key", []model.Transaction{payment})
+	if len(saved) != 1 || replayed || err != nil {
+		t.Fatal("SubmitTransactions failed:", saved, replayed, err)
+	}
+
+	// The retry is signed again, and returns the original result
+	SignInputs(&payment, config.InitConfig().GenesisPrivKey)
+	again, _, replayed, err := SubmitTransactions("key", []model.Transaction{payment})
+
+	if len(again) != 1 || again[0].TxID != saved[0].TxID || !replayed || err != nil {
+		t.Error("Original result not returned:", again, replayed, err)
+	}
+
+	// The key must not be used for another batch
+	other := newPayment(genesis, wallet.PubKey, 200)
+	if _, _, _, err := SubmitTransactions("key", []model.Transaction{other}); !errors.Is(err, ErrIdempotencyKeyReused) || ErrorCode(err) != CodeIdempotencyKeyReused {
+		t.Error("Reused key was accepted:", err)
+	}
+}
+
+func TestSubmitTransactionsExpiredKey(t *testing.T) {
+	defer setIdempotencyStore(10 * time.Millisecond)()
+
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	SubmitTransactions("key", []model.Transaction{newPayment(genesis, wallet.PubKey, 100)})
+
+	time.Sleep(20 * time.Millisecond)
+
+	// The key expired, so another batch may use it
+	other := newPayment(genesis, wallet.PubKey, 200)
+	if _, _, _, err := SubmitTransactions("key", []model.Transaction{other}); ErrorCode(err) != CodeAlreadySpent {
+		t.Error("Expired key was not removed:", err)
+	}
+}
+
+func TestSubmitTransactionsExisting(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	payment := newPayment(genesis, wallet.PubKey, 100)
+
+	saved, _, _, err := SubmitTransactions("", []model.Transaction{payment})
+	if err != nil {
+		t.Fatal("SubmitTransactions failed:", err)
+	}
+
+	// A retry without a key finds the saved transactions
+	again, _, replayed, err := SubmitTransactions("", []model.Transaction{payment})
+
+	if len(again) != 1 || again[0].TxID != saved[0].TxID || !replayed || err != nil {
+		t.Error("Saved transactions not returned:", again, replayed, err)
+	}
+
+	// A batch with a new transaction is still rejected
+	other := newPayment(genesis, wallet.PubKey, 200)
+	if _, _, _, err := SubmitTransactions("", []model.Transaction{payment, other}); err == nil {
+		t.Error("Batch spending a spent output was accepted")
+	}
+}
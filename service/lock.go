@@ -0,0 +1,66 @@
+package service
+
+import (
+	"sort"
+	"sync"
+)
+
+// keyLocks is a set of mutexes identified by a key. It is used to serialize spends of the
+// same previous transaction, so only one batch at a time checks and saves a spend of it.
+type keyLocks struct {
+	mutex sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyLocks() *keyLocks {
+	l := new(keyLocks)
+	l.locks = make(map[string]*keyLock)
+	return l
+}
+
+// Lock locks all the keys and returns a function to unlock them. Keys are always locked
+// in sorted order, so two callers locking overlapping keys cannot deadlock.
+func (l *keyLocks) Lock(keys []string) func() {
+	sorted := make([]string, 0, len(keys))
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+
+	held := make([]*keyLock, len(sorted))
+	for i, key := range sorted {
+		l.mutex.Lock()
+		lock, contains := l.locks[key]
+		if !contains {
+			lock = new(keyLock)
+			l.locks[key] = lock
+		}
+		lock.refs++
+		l.mutex.Unlock()
+
+		lock.Lock()
+		held[i] = lock
+	}
+
+	return func() {
+		for i, key := range sorted {
+			held[i].Unlock()
+
+			l.mutex.Lock()
+			held[i].refs--
+			if held[i].refs == 0 {
+				delete(l.locks, key)
+			}
+			l.mutex.Unlock()
+		}
+	}
+}
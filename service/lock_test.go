@@ -0,0 +1,54 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyLocks(t *testing.T) {
+	l := newKeyLocks()
+	unlock := l.Lock([]string{"a", "b", "a"})
+
+	locked := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		unlock := l.Lock([]string{"b"})
+		locked <- true
+		unlock()
+		done <- true
+	}()
+
+	select {
+	case <-locked:
+		t.Error("Key locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+	<-done
+
+	if len(l.locks) != 0 {
+		t.Error("Locks not released:", l.locks)
+	}
+}
+
+func TestKeyLocksOverlapping(t *testing.T) {
+	l := newKeyLocks()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			l.Lock([]string{"a", "b"})()
+		}()
+		go func() {
+			defer wg.Done()
+			l.Lock([]string{"b", "a"})()
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotInLog is returned when a transaction exists but is not in the log of the tree size: it is pending,
+// or it was appended after.
+var ErrNotInLog = errors.New("Transaction is not in the log")
+
+// maxLogEntries is the maximum number of entries returned by GetLogEntries.
+const maxLogEntries = 100
+
+// transparencyLog keeps the Merkle tree of the log of the repository (see repository.Repository.LogSize).
+// The log is only appended to, so the tree is synced by appending the new entries.
+type transparencyLog struct {
+	mutex sync.Mutex
+	tree  *model.MerkleTree
+}
+
+var txLog = newTransparencyLog()
+
+func newTransparencyLog() *transparencyLog {
+	l := new(transparencyLog)
+	l.tree = model.NewMerkleTree(nil)
+	return l
+}
+
+// sync appends the entries of the log which are not in the tree, and returns the size of the log. The
+// caller holds the mutex.
+func (l *transparencyLog) sync() (int, error) {
+	size, err := repo.LogSize()
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	for int64(l.tree.Size()) < size {
+		entries, err := repo.GetLogEntries(int64(l.tree.Size()), size)
+		if err != nil {
+			return 0, storageError(err)
+		}
+		if len(entries) == 0 {
+			return 0, storageError(errors.New("Log entries are missing"))
+		}
+
+		for _, txID := range entries {
+			l.tree.Append([]byte(txID))
+		}
+	}
+
+	return l.tree.Size(), nil
+}
+
+// GetTreeHead returns the signed tree head of the whole log.
+func GetTreeHead() (*model.TreeHead, error) {
+	txLog.mutex.Lock()
+	defer txLog.mutex.Unlock()
+
+	size, err := txLog.sync()
+	if err != nil {
+		return nil, err
+	}
+
+	root, _ := txLog.tree.Root(size)
+	head := &model.TreeHead{TreeSize: int64(size), Timestamp: time.Now().UTC().Truncate(time.Millisecond), RootHash: hex.EncodeToString(root)}
+
+	head.PubKey, head.Signature, err = signOperator(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return head, nil
+}
+
+// GetConsistencyProof returns the proof that the log of size from is a prefix of the log of size to. A
+// negative to is the size of the log.
+func GetConsistencyProof(from int64, to int64) (*model.ConsistencyProof, error) {
+	txLog.mutex.Lock()
+	defer txLog.mutex.Unlock()
+
+	size, err := txLog.sync()
+	if err != nil {
+		return nil, err
+	}
+
+	if to < 0 {
+		to = int64(size)
+	}
+
+	if to > int64(size) {
+		return nil, errors.New("Tree size must not be greater than the size of the log")
+	}
+
+	proof, err := txLog.tree.ConsistencyProof(int(from), int(to))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ConsistencyProof{From: from, To: to, Proof: hexHashes(proof)}, nil
+}
+
+// GetInclusionProof returns the audit path of the transaction in the log of the tree size. A negative
+// size is the size of the log.
+func GetInclusionProof(txID string, size int64) (*model.InclusionProof, error) {
+	index, err := repo.GetLogIndex(txID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	txLog.mutex.Lock()
+	defer txLog.mutex.Unlock()
+
+	logSize, err := txLog.sync()
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		size = int64(logSize)
+	}
+
+	if size > int64(logSize) {
+		return nil, errors.New("Tree size must not be greater than the size of the log")
+	}
+
+	if index < 0 || index >= size {
+		if _, err := GetTransaction(txID); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotInLog
+	}
+
+	path, err := txLog.tree.InclusionProof(int(index), int(size))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.InclusionProof{TxID: txID, LeafIndex: index, TreeSize: size, Path: hexHashes(path)}, nil
+}
+
+// GetLogEntries returns the TxIDs of the log from index start to index end, excluded, at most
+// maxLogEntries.
+func GetLogEntries(start int64, end int64) ([]string, error) {
+	if start < 0 || end < start {
+		return nil, errors.New("Log range is not valid")
+	}
+
+	if end-start > maxLogEntries {
+		end = start + maxLogEntries
+	}
+
+	entries, err := repo.GetLogEntries(start, end)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if entries == nil {
+		entries = []string{}
+	}
+
+	return entries, nil
+}
+
+// hexHashes returns the hex encoding of the hashes.
+func hexHashes(hashes [][]byte) []string {
+	results := make([]string, len(hashes))
+	for i, hash := range hashes {
+		results[i] = hex.EncodeToString(hash)
+	}
+	return results
+}
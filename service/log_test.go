@@ -0,0 +1,77 @@
+package service
+
+import (
+	"cryptocoin-server/client"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"testing"
+)
+
+func TestTransparencyLog(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	key, _ := ecdsa.ParsePrivKey(operatorPrivKey)
+	operator := ecdsa.ExportPubKey(&key.PublicKey)
+
+	genesis, _ := CreateGenesisTransaction()
+	first, err := GetTreeHead()
+	if first == nil || first.TreeSize != 1 || err != nil {
+		t.Fatal("GetTreeHead failed:", first, err)
+	}
+
+	if err := client.VerifyTreeHead(first, operator); err != nil {
+		t.Error("Tree head not verified:", err)
+	}
+
+	// A pending transaction is appended when its block is saved
+	transfer, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	if _, err := GetInclusionProof(transfer.TxID, -1); err != ErrNotInLog || ErrorCode(err) != CodeNotInLog {
+		t.Error("Inclusion proof of a pending transaction returned:", err)
+	}
+
+	ConfirmPending()
+
+	second, _ := GetTreeHead()
+	if second.TreeSize != 2 {
+		t.Error("Tree size does not match:", second.TreeSize)
+	}
+
+	consistency, err := GetConsistencyProof(first.TreeSize, -1)
+	if err != nil {
+		t.Fatal("GetConsistencyProof failed:", err)
+	}
+
+	if err := client.VerifyConsistencyProof(consistency, first, second); err != nil {
+		t.Error("Consistency proof not verified:", err)
+	}
+
+	for _, txID := range []string{genesis.TxID, transfer.TxID} {
+		inclusion, err := GetInclusionProof(txID, second.TreeSize)
+		if err != nil {
+			t.Fatal("GetInclusionProof failed:", err)
+		}
+
+		if err := client.VerifyInclusionProof(inclusion, second); err != nil {
+			t.Error("Inclusion proof not verified:", txID, err)
+		}
+	}
+
+	if _, err := GetInclusionProof(transfer.TxID, first.TreeSize); err != ErrNotInLog {
+		t.Error("Inclusion proof in a log of a smaller size returned:", err)
+	}
+
+	if _, err := GetInclusionProof("unknown", -1); err != ErrNotFound {
+		t.Error("ErrNotFound not returned:", err)
+	}
+
+	if _, err := GetConsistencyProof(2, 3); err == nil || ErrorCode(err) != CodeInvalidRequest {
+		t.Error("Consistency proof of a size greater than the log returned:", err)
+	}
+
+	if entries, err := GetLogEntries(0, 10); len(entries) != 2 || entries[0] != genesis.TxID || entries[1] != transfer.TxID || err != nil {
+		t.Error("Log entries do not match:", entries, err)
+	}
+}
@@ -0,0 +1,589 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrMempoolFull is returned when the mempool has no room for the transactions.
+var ErrMempoolFull = errors.New("Mempool is full, try again later")
+
+// mempool keeps the validated transactions which are not saved to the ledger yet (pending). Pending
+// transactions are confirmed in blocks: when there are batchSize pending transactions, and at the
+// interval of StartMempool. The transactions of a batch added together are always confirmed in the
+// same block, so they are saved together or not at all. The mempool is only kept in memory, the pending
+// transactions are lost when the server stops without confirming them. The last dropped transactions
+// are kept, so their status tells they will not be confirmed.
+type mempool struct {
+	mutex     sync.RWMutex
+	confirm   sync.Mutex // Only one batch is confirmed at a time
+	batchSize int
+	maxSize   int   // Maximum number of pending transactions
+	sequence  int64 // Sequence number of the last accepted transaction
+	reserved  int64 // Last sequence number reserved in the repository
+	pending   map[string]*pendingTransaction
+	spends    map[model.Outpoint]string // Outputs spent by pending transactions -> TxID of the transaction spending it
+	dropped   map[string]string         // TxID of a dropped transaction -> reason, at most maxSize
+	drops     []string                  // TxIDs of dropped, in the order they were dropped
+	wakeup    chan bool                 // Wakes up StartMempool to confirm a full mempool
+}
+
+type pendingTransaction struct {
+	transaction model.Transaction
+	batch       *pendingBatch
+}
+
+// pendingBatch is a batch of transactions added together, with its fee transaction.
+type pendingBatch struct {
+	txIDs    []string // In the order of the batch
+	feeRate  float64  // Fee per byte of the canonical encodings
+	sequence int64    // Order of arrival
+}
+
+// sequenceReservation is the number of sequence numbers the mempool reserves in the repository at a time.
+// The numbers reserved by a mempool are not used by the next one, so a restarted server skips the unused
+// numbers and does not reuse the numbers of pending transactions which were lost.
+const sequenceReservation = 1000
+
+// mempoolBatchSize is the batch size of the mempool created by InitService.
+var mempoolBatchSize = config.InitConfig().MempoolBatchSize
+
+// mempoolMaxSize is the maximum number of pending transactions of the mempools.
+var mempoolMaxSize = config.InitConfig().MempoolMaxSize
+
+var pool = newMempool(mempoolBatchSize)
+
+func newMempool(batchSize int) *mempool {
+	m := new(mempool)
+	m.batchSize = batchSize
+	m.maxSize = mempoolMaxSize
+	m.pending = make(map[string]*pendingTransaction)
+	m.spends = make(map[model.Outpoint]string)
+	m.dropped = make(map[string]string)
+	m.wakeup = make(chan bool, 1)
+	return m
+}
+
+// mempoolStopTimeout is the time the function returned by StartMempool takes at most to confirm the
+// remaining pending transactions, mining is stopped after it.
+var mempoolStopTimeout = 10 * time.Second
+
+// StartMempool confirms the pending transactions at the interval, so a block is sealed at least every
+// interval while transactions are pending, and when the mempool is full with proof of work (see
+// AddTransactions). It returns a function which stops it, stopping the block being mined, and confirms
+// the remaining pending transactions.
+func StartMempool(interval time.Duration) func() {
+	stop := make(chan bool)
+	stopped := make(chan bool)
+	m := pool
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		defer close(stopped)
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.confirmBatch(stop, nil); err != nil {
+					log.Print(err)
+				}
+			case <-m.wakeup:
+				for m.full() {
+					if block, err := m.confirmBatch(stop, nil); err != nil || block == nil {
+						if err != nil {
+							log.Print(err)
+						}
+						break
+					}
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+
+		timeout := make(chan bool)
+		timer := time.AfterFunc(mempoolStopTimeout, func() { close(timeout) })
+		defer timer.Stop()
+
+		// A batch which fails is not tried again, the next ones are still confirmed
+		failed := make(map[*pendingBatch]bool)
+		for {
+			block, err := m.confirmBatch(timeout, failed)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			if block == nil {
+				return
+			}
+		}
+	}
+}
+
+// ConfirmPending seals the next batch of pending transactions into a block, saves it to the ledger and
+// returns it. It returns nil if there are no pending transactions.
+func ConfirmPending() (*model.Block, error) {
+	return pool.confirmBatch(nil, nil)
+}
+
+// GetTransactionStatus returns whether the transaction is pending, confirmed or dropped, or ErrNotFound.
+func GetTransactionStatus(txID string) (*model.TransactionStatus, error) {
+	if _, contains := pool.get(txID); contains {
+		return &model.TransactionStatus{TxID: txID, Status: model.StatusPending}, nil
+	}
+
+	t, err := repo.GetTransaction(txID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if t != nil {
+		return &model.TransactionStatus{TxID: txID, Status: model.StatusConfirmed}, nil
+	}
+
+	if reason, dropped := pool.droppedReason(txID); dropped {
+		return &model.TransactionStatus{TxID: txID, Status: model.StatusDropped, Reason: reason}, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// lookupOutputs returns the transactions of the outputs from the pending and the confirmed transactions.
+// An output spent by a pending transaction is spent. The pending transactions are checked first, so a
+// transaction confirmed meanwhile is found in the ledger.
+func lookupOutputs(outpoints []model.Outpoint) (*repository.Lookup, error) {
+	lookup := &repository.Lookup{Unspent: make(map[model.Outpoint]*model.Transaction)}
+
+	var confirmed []model.Outpoint
+	pool.mutex.RLock()
+	for _, outpoint := range outpoints {
+		_, spent := pool.spends[outpoint]
+		p, pending := pool.pending[outpoint.TxID]
+
+		switch {
+		case spent:
+			lookup.Spent = append(lookup.Spent, outpoint)
+		case pending && p.transaction.Output(outpoint) == nil:
+			lookup.Missing = append(lookup.Missing, outpoint)
+		case pending:
+			t := p.transaction
+			lookup.Unspent[outpoint] = &t
+		default:
+			confirmed = append(confirmed, outpoint)
+		}
+	}
+	pool.mutex.RUnlock()
+
+	if len(confirmed) == 0 {
+		return lookup, nil
+	}
+
+	result, err := repo.LookupOutputs(confirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	for outpoint, t := range result.Unspent {
+		lookup.Unspent[outpoint] = t
+	}
+	lookup.Spent = append(lookup.Spent, result.Spent...)
+	lookup.Missing = append(lookup.Missing, result.Missing...)
+
+	return lookup, nil
+}
+
+// add adds the validated transactions as pending. fees are the fees of the transactions by TxID.
+// It returns the unsigned receipts of the transactions, ErrAlreadySpent if a pending transaction
+// spends an output of the transactions, or ErrMempoolFull.
+func (m *mempool) add(transactions []model.Transaction, fees map[string]int64) ([]model.Receipt, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.pending)+len(transactions) > m.maxSize {
+		return nil, ErrMempoolFull
+	}
+
+	for _, t := range transactions {
+		for _, input := range t.Inputs {
+			if txID, contains := m.spends[input.Outpoint]; contains {
+				return nil, &repository.CommitError{TxID: t.TxID, Err: fmt.Errorf("%w: %s by pending transaction %s", repository.ErrAlreadySpent, input.Outpoint, txID)}
+			}
+		}
+	}
+
+	acceptances, err := m.accept(len(transactions))
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := make([]model.Receipt, len(transactions))
+	batch := &pendingBatch{sequence: acceptances[0].Sequence}
+
+	var fee int64
+	var size int
+	for i, t := range transactions {
+		txSize, _ := t.Size()
+		fee += fees[t.TxID]
+		size += txSize
+
+		t.Acceptance = &acceptances[i]
+		m.pending[t.TxID] = &pendingTransaction{transaction: t, batch: batch}
+		batch.txIDs = append(batch.txIDs, t.TxID)
+		receipts[i] = model.Receipt{TxID: t.TxID, AcceptedAt: acceptances[i].AcceptedAt, Sequence: acceptances[i].Sequence}
+
+		for _, input := range t.Inputs {
+			m.spends[input.Outpoint] = t.TxID
+		}
+	}
+	batch.feeRate = float64(fee) / float64(size)
+
+	return receipts, nil
+}
+
+// get returns a copy of the pending transaction.
+func (m *mempool) get(txID string) (*model.Transaction, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	p, contains := m.pending[txID]
+	if !contains {
+		return nil, false
+	}
+
+	t := p.transaction
+	return &t, true
+}
+
+// accept returns the acceptance of count transactions accepted now, with the next sequence numbers. More
+// numbers are reserved in the repository when the reserved ones are used. The caller must hold the lock.
+func (m *mempool) accept(count int) ([]model.Acceptance, error) {
+	if m.sequence+int64(count) > m.reserved {
+		reserve := int64(sequenceReservation)
+		if int64(count) > reserve {
+			reserve = int64(count)
+		}
+
+		last, err := repo.ReserveSequence(reserve)
+		if err != nil {
+			return nil, storageError(err)
+		}
+
+		// The rest of the previous reservation is used if the new one follows it
+		if last-reserve > m.sequence {
+			m.sequence = last - reserve
+		}
+		m.reserved = last
+	}
+
+	now := time.Now().UTC()
+	acceptances := make([]model.Acceptance, count)
+	for i := range acceptances {
+		m.sequence++
+		acceptances[i] = model.Acceptance{AcceptedAt: now, Sequence: m.sequence}
+	}
+
+	return acceptances, nil
+}
+
+// size returns the number of pending transactions.
+func (m *mempool) size() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.pending)
+}
+
+// full returns true if there are enough pending transactions for a batch.
+func (m *mempool) full() bool {
+	return m.size() >= m.batchSize
+}
+
+// wake wakes up StartMempool, unless it is already woken up.
+func (m *mempool) wake() {
+	select {
+	case m.wakeup <- true:
+	default:
+	}
+}
+
+// next returns the transactions of the next block: whole batches of pending transactions, ordered by
+// fee rate and then by arrival, up to batchSize transactions unless the first batch is larger. A batch is
+// only selected after the pending transactions it spends and the transactions it collects the fees of, so
+// the block can be saved in order. The batches of skip are not selected, nor the batches depending on
+// them. The caller must hold the lock.
+func (m *mempool) next(skip map[*pendingBatch]bool) ([]model.Transaction, []*pendingBatch) {
+	var ordered []*pendingBatch
+	seen := make(map[*pendingBatch]bool)
+	for _, p := range m.pending {
+		if !seen[p.batch] && !skip[p.batch] {
+			seen[p.batch] = true
+			ordered = append(ordered, p.batch)
+		}
+	}
+
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].feeRate != ordered[j].feeRate {
+			return ordered[i].feeRate > ordered[j].feeRate
+		}
+		return ordered[i].sequence < ordered[j].sequence
+	})
+
+	var transactions []model.Transaction
+	var batches []*pendingBatch
+	chosen := make(map[*pendingBatch]bool)
+	selected := make(map[string]bool)
+
+	for progress := true; progress && len(transactions) < m.batchSize; {
+		progress = false
+
+		for _, b := range ordered {
+			full := len(transactions) > 0 && len(transactions)+len(b.txIDs) > m.batchSize
+			if chosen[b] || full || !m.ready(b, selected) {
+				continue
+			}
+
+			chosen[b] = true
+			batches = append(batches, b)
+			for _, txID := range b.txIDs {
+				selected[txID] = true
+				transactions = append(transactions, m.pending[txID].transaction)
+			}
+			progress = true
+		}
+	}
+
+	return transactions, batches
+}
+
+// ready returns true if the pending transactions the batch depends on are selected or in the batch.
+func (m *mempool) ready(b *pendingBatch, selected map[string]bool) bool {
+	inBatch := make(map[string]bool)
+	for _, txID := range b.txIDs {
+		inBatch[txID] = true
+	}
+
+	dependency := func(txID string) bool {
+		_, pending := m.pending[txID]
+		return pending && !selected[txID] && !inBatch[txID]
+	}
+
+	for _, txID := range b.txIDs {
+		t := &m.pending[txID].transaction
+		for _, input := range t.Inputs {
+			if dependency(input.Outpoint.TxID) {
+				return false
+			}
+		}
+		for _, collected := range t.Collects {
+			if dependency(collected) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// confirmBatch seals the next batches into the block following the last block, saves it to the ledger
+// and removes them from the pending transactions. Mining the block is stopped when stop is closed. The
+// batches of failed are not selected, and the selected batches are added to it if the block fails.
+func (m *mempool) confirmBatch(stop <-chan bool, failed map[*pendingBatch]bool) (block *model.Block, err error) {
+	m.confirm.Lock()
+	defer m.confirm.Unlock()
+
+	m.mutex.RLock()
+	batch, batches := m.next(failed)
+	m.mutex.RUnlock()
+
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	defer func() {
+		if err != nil && failed != nil {
+			for _, b := range batches {
+				failed[b] = true
+			}
+		}
+	}()
+
+	// The transactions stay pending until they are saved, lookups check the pending transactions first
+	block, err = saveBlock(batch, stop)
+	if err == ErrMiningStopped {
+		return nil, err
+	} else if err != nil {
+		// A transaction spending an output spent in the ledger or missing, or saved already, can never be
+		// saved, it is dropped so it does not stop the next batches
+		if errors.Is(err, repository.ErrAlreadySpent) || errors.Is(err, repository.ErrMissingOutput) || errors.Is(err, repository.ErrTransactionExists) {
+			m.drop(rejectedTxIDs(batch, err), err)
+		}
+		return nil, storageError(err)
+	}
+
+	m.mutex.Lock()
+	for _, t := range batch {
+		delete(m.pending, t.TxID)
+		for _, input := range t.Inputs {
+			delete(m.spends, input.Outpoint)
+		}
+	}
+	m.mutex.Unlock()
+
+	return block, nil
+}
+
+// saveBlock seals the transactions into the block following the last block and saves it to the ledger.
+// Mining the block is stopped when stop is closed. The caller must hold the confirm lock of the mempool,
+// so the blocks are saved one at a time.
+func saveBlock(transactions []model.Transaction, stop <-chan bool) (*model.Block, error) {
+	last, err := repo.LastBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	txIDs := make([]string, len(transactions))
+	for i, t := range transactions {
+		txIDs[i] = t.TxID
+	}
+	block := model.NewBlock(last, txIDs)
+
+	// The target is the one of the retarget schedule after last, the repository checks that the block
+	// still follows last when it is saved
+	if miner.required(last) {
+		if err := miner.seal(block, last, stop); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := repo.SaveBlock(block, transactions); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// rejectedTxIDs returns the TxID of the transaction of the batch which failed to save, or all of them.
+func rejectedTxIDs(batch []model.Transaction, err error) []string {
+	var commitErr *repository.CommitError
+	if errors.As(err, &commitErr) && commitErr.TxID != "" {
+		return []string{commitErr.TxID}
+	}
+
+	txIDs := make([]string, len(batch))
+	for i, t := range batch {
+		txIDs[i] = t.TxID
+	}
+	return txIDs
+}
+
+// drop removes the pending transactions with the other transactions of their batch, and the pending
+// transactions spending their outputs or collecting their fees, which can not be saved without them.
+func (m *mempool) drop(txIDs []string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for len(txIDs) > 0 {
+		txID := txIDs[0]
+		txIDs = txIDs[1:]
+
+		p, contains := m.pending[txID]
+		if !contains {
+			continue
+		}
+
+		log.Printf("Dropped pending transaction %s: %v", txID, err)
+		delete(m.pending, txID)
+		// A transaction sent again after it was dropped is pending or confirmed, which its status checks first
+		if _, contains := m.dropped[txID]; !contains {
+			m.drops = append(m.drops, txID)
+		}
+		m.dropped[txID] = err.Error()
+		if len(m.drops) > m.maxSize {
+			delete(m.dropped, m.drops[0])
+			m.drops = m.drops[1:]
+		}
+		for _, input := range p.transaction.Inputs {
+			if m.spends[input.Outpoint] == txID {
+				delete(m.spends, input.Outpoint)
+			}
+		}
+		txIDs = append(txIDs, p.batch.txIDs...)
+
+		for _, other := range m.pending {
+			if dependsOn(&other.transaction, txID) {
+				txIDs = append(txIDs, other.transaction.TxID)
+			}
+		}
+	}
+}
+
+// droppedReason returns why the transaction was dropped, if it is one of the last dropped transactions.
+func (m *mempool) droppedReason(txID string) (string, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	reason, dropped := m.dropped[txID]
+	return reason, dropped
+}
+
+// dependsOn returns true if the transaction spends an output of the transaction of the TxID, or collects
+// its fee.
+func dependsOn(t *model.Transaction, txID string) bool {
+	for _, input := range t.Inputs {
+		if input.Outpoint.TxID == txID {
+			return true
+		}
+	}
+
+	for _, collected := range t.Collects {
+		if collected == txID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pendingBalance returns the balance of the public key including the pending transactions, from the
+// confirmed unspent outputs, and the number of pending transactions sending to or spending from it.
+func (m *mempool) pendingBalance(pubKey string, confirmed []model.UnspentOutput) (int64, int) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var balance int64
+	for _, output := range confirmed {
+		if _, spent := m.spends[output.Outpoint]; !spent {
+			balance += output.Value
+		}
+	}
+
+	count := 0
+	for _, p := range m.pending {
+		t := &p.transaction
+		if !t.HasOutputTo(pubKey) && !t.HasInputFrom(pubKey) {
+			continue
+		}
+
+		count++
+		for i, output := range t.Outputs {
+			if _, spent := m.spends[t.Outpoint(i)]; output.ToAddress == pubKey && !spent {
+				balance += output.Value
+			}
+		}
+	}
+
+	return balance, count
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMempoolPending(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, err := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	if err != nil {
+		t.Fatal("TransferFromGenesisAccount failed:", err)
+	}
+
+	if status, err := GetTransactionStatus(first.TxID); status == nil || status.Status != model.StatusPending || err != nil {
+		t.Error("Transaction is not pending:", status, err)
+	}
+
+	if saved, _ := repo.GetTransaction(first.TxID); saved != nil {
+		t.Error("Pending transaction was saved:", saved)
+	}
+
+	// The change of a pending transaction can be spent
+	second, err := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 50)
+	if err != nil {
+		t.Fatal("Pending output was not spent:", err)
+	}
+
+	// A pending spend conflicts with another spend of the same output
+	conflict := newPayment(genesis, wallet.PubKey, 10)
+	if err := AddTransactions([]model.Transaction{conflict}); ErrorCode(err) != CodeAlreadySpent {
+		t.Error("Conflicting transaction was accepted:", err)
+	}
+
+	w, _ := GetWallet(wallet.PubKey)
+	if w.Balanance != 0 || w.PendingBalance != 150 || w.PendingCount != 2 {
+		t.Error("Pending balance does not match:", w)
+	}
+
+	block, err := ConfirmPending()
+	if block == nil || len(block.TxIDs) != 2 || block.TxIDs[0] != first.TxID || err != nil {
+		t.Fatal("ConfirmPending failed:", block, err)
+	}
+
+	if status, _ := GetTransactionStatus(second.TxID); status.Status != model.StatusConfirmed {
+		t.Error("Transaction is not confirmed:", status)
+	}
+
+	w, _ = GetWallet(wallet.PubKey)
+	if w.Balanance != 150 || w.PendingBalance != 150 || w.PendingCount != 0 {
+		t.Error("Confirmed balance does not match:", w)
+	}
+
+	if _, err := GetTransactionStatus("unknown"); err != ErrNotFound {
+		t.Error("ErrNotFound not returned:", err)
+	}
+}
+
+func TestMempoolOrder(t *testing.T) {
+	defer setFeePolicy(0, 0)()
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+	genesisKey := config.InitConfig().GenesisPubKey
+
+	low, _ := CreateGenesisTransaction()
+	high, _ := CreateGenesisTransaction()
+
+	payLow := newPayment(low, wallet.PubKey, 100)
+	payLow.Outputs[1].Value -= 1
+	SignInputs(&payLow, config.InitConfig().GenesisPrivKey)
+
+	payHigh := newPayment(high, wallet.PubKey, 100)
+	payHigh.Outputs[1].Value -= 1000
+	SignInputs(&payHigh, config.InitConfig().GenesisPrivKey)
+
+	AddTransactions([]model.Transaction{payLow})
+	AddTransactions([]model.Transaction{payHigh})
+
+	// The child pays a higher fee than its parent, but it is confirmed after it
+	payLow.TxID, _ = payLow.ID()
+	child := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: payLow.Outpoint(1), PubKey: genesisKey}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: payLow.Outputs[1].Value - 100000}},
+	}
+	SignInputs(&child, config.InitConfig().GenesisPrivKey)
+
+	if err := AddTransactions([]model.Transaction{child}); err != nil {
+		t.Fatal("AddTransactions failed:", err)
+	}
+
+	pool.mutex.RLock()
+	batch, _ := pool.next(nil)
+	pool.mutex.RUnlock()
+
+	var order []string
+	for _, tx := range batch {
+		if !tx.IsFee() {
+			order = append(order, tx.TxID)
+		}
+	}
+
+	payHigh.TxID, _ = payHigh.ID()
+	child.TxID, _ = child.ID()
+	if len(order) != 3 || order[0] != payHigh.TxID || order[1] != payLow.TxID || order[2] != child.TxID {
+		t.Error("Order does not match:", order)
+	}
+}
+
+func TestStartMempool(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	second, _ := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+
+	stop := StartMempool(10 * time.Millisecond)
+
+	for i := 0; i < 100 && pool.size() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status, _ := GetTransactionStatus(first.TxID); status == nil || status.Status != model.StatusConfirmed {
+		t.Error("Transaction was not confirmed at the interval:", status)
+	}
+
+	// Stopping confirms the rest
+	third, _ := TransferFromGenesisAccount(second.TxID, wallet.PubKey, 100)
+	stop()
+
+	if status, _ := GetTransactionStatus(third.TxID); status == nil || status.Status != model.StatusConfirmed {
+		t.Error("Transaction was not confirmed when stopped:", status)
+	}
+}
+
+func TestMempoolDropsUnsavable(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	spent, _ := CreateGenesisTransaction()
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(spent.TxID, wallet.PubKey, 100)
+	child, _ := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+	other, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+
+	// The output spent by the first pending transaction is spent in the ledger meanwhile
+	conflict := newPayment(spent, wallet.PubKey, 10)
+	conflict.TxID, _ = conflict.ID()
+	repo.SaveTransactions([]model.Transaction{conflict})
+
+	if _, err := ConfirmPending(); ErrorCode(err) != CodeAlreadySpent {
+		t.Error("Unsavable batch was confirmed:", err)
+	}
+
+	for _, txID := range []string{first.TxID, child.TxID} {
+		if status, err := GetTransactionStatus(txID); status == nil || status.Status != model.StatusDropped || status.Reason == "" || err != nil {
+			t.Error("Unsavable transaction was not dropped:", txID, status, err)
+		}
+	}
+
+	block, err := ConfirmPending()
+	if block == nil || len(block.TxIDs) != 1 || block.TxIDs[0] != other.TxID || err != nil {
+		t.Error("Next batch was not confirmed:", block, err)
+	}
+}
+
+func TestMempoolKeepsLastDropped(t *testing.T) {
+	m := newMempool(10)
+	m.maxSize = 1
+
+	for _, txID := range []string{"a", "b"} {
+		m.pending[txID] = &pendingTransaction{transaction: model.Transaction{TxID: txID}, batch: &pendingBatch{txIDs: []string{txID}}}
+		m.drop([]string{txID}, errors.New("Dropped"))
+	}
+
+	if _, dropped := m.droppedReason("a"); dropped {
+		t.Error("More than maxSize dropped transactions kept")
+	}
+
+	if reason, dropped := m.droppedReason("b"); !dropped || reason != "Dropped" {
+		t.Error("Last dropped transaction not kept:", reason)
+	}
+}
+
+func TestMempoolFull(t *testing.T) {
+	defer func(maxSize int) { mempoolMaxSize = maxSize }(mempoolMaxSize)
+	mempoolMaxSize = 1
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+
+	if _, err := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100); err != ErrMempoolFull || ErrorCode(err) != CodeMempoolFull {
+		t.Error("Transaction was accepted in a full mempool:", err)
+	}
+
+	ConfirmPending()
+
+	if _, err := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100); err != nil {
+		t.Error("Transaction was not accepted after confirming:", err)
+	}
+}
+
+func TestMempoolBatchNotSplit(t *testing.T) {
+	defer setFeePolicy(1, 0)()
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(2)
+	wallet, _ := model.NewWallet()
+
+	var batch []model.Transaction
+	for i := 0; i < 2; i++ {
+		genesis, _ := CreateGenesisTransaction()
+		payment := newPayment(genesis, wallet.PubKey, 100)
+		payment.Outputs[1].Value -= 1000
+		SignInputs(&payment, config.InitConfig().GenesisPrivKey)
+		batch = append(batch, payment)
+	}
+
+	if err := AddTransactions(batch); err != nil {
+		t.Fatal("AddTransactions failed:", err)
+	}
+
+	// The two payments and the fee transaction are confirmed together, even though the batch size is 2
+	block, _ := repo.LastBlock()
+	if block == nil || len(block.TxIDs) != 3 || pool.size() != 0 {
+		t.Error("Batch was split:", block, pool.size())
+	}
+}
+
+// blockFailingRepository fails to save the blocks containing a transaction.
+type blockFailingRepository struct {
+	*repository.MemoryRepository
+	txID string
+}
+
+func (r blockFailingRepository) SaveBlock(block *model.Block, transactions []model.Transaction) error {
+	for _, txID := range block.TxIDs {
+		if txID == r.txID {
+			return &repository.CommitError{Err: errors.New("Connection refused")}
+		}
+	}
+	return r.MemoryRepository.SaveBlock(block, transactions)
+}
+
+func TestStopMempoolAfterFailure(t *testing.T) {
+	r := blockFailingRepository{MemoryRepository: repository.NewMemoryRepository()}
+	InitService(&r)
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	first, _ := CreateGenesisTransaction()
+	second, _ := CreateGenesisTransaction()
+	failing, _ := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+	saved, _ := TransferFromGenesisAccount(second.TxID, wallet.PubKey, 100)
+	r.txID = failing.TxID
+
+	// Each batch is confirmed in its own block when the mempool stops
+	pool.batchSize = 1
+	StartMempool(time.Hour)()
+
+	if status, _ := GetTransactionStatus(saved.TxID); status == nil || status.Status != model.StatusConfirmed {
+		t.Error("Batch after a failed batch was not confirmed:", status)
+	}
+
+	if status, _ := GetTransactionStatus(failing.TxID); status == nil || status.Status != model.StatusPending {
+		t.Error("Failed batch is not pending:", status)
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMiningStopped is returned when the mining of a block is stopped before a nonce was found.
+var ErrMiningStopped = errors.New("Mining was stopped")
+
+// proofOfWork seals the blocks with proof of work when it is enabled. threads goroutines search the nonce
+// which makes the hash of the header meet the target, and the target is adjusted every window blocks so
+// a block takes blockTime.
+type proofOfWork struct {
+	enabled     bool
+	threads     int
+	initialBits int
+	window      int64
+	blockTime   time.Duration
+
+	mutex    sync.Mutex
+	mining   bool
+	hashes   uint64        // Hashes of all the mined blocks
+	duration time.Duration // Time spent mining
+	blocks   int64
+}
+
+var miner = newProofOfWork(config.InitConfig())
+
+// stopCheckInterval is the number of hashes between two checks of the stop channel by a mining thread.
+const stopCheckInterval = 1 << 12
+
+func newProofOfWork(config *config.Config) *proofOfWork {
+	p := new(proofOfWork)
+	p.enabled = config.ProofOfWork
+	p.threads = config.MiningThreads
+	p.initialBits = config.MiningInitialBits
+	p.window = config.MiningRetargetWindow
+	p.blockTime = config.MiningBlockTime
+
+	if p.threads < 1 {
+		p.threads = 1
+	}
+
+	return p
+}
+
+// GetMiningStatus returns the state of the miner and the target of the next block.
+func GetMiningStatus() (*model.MiningStatus, error) {
+	status := &model.MiningStatus{Enabled: miner.enabled, Threads: miner.threads}
+
+	miner.mutex.Lock()
+	status.Mining = miner.mining
+	status.Hashes = miner.hashes
+	status.BlocksMined = miner.blocks
+	if miner.duration > 0 {
+		status.Hashrate = float64(miner.hashes) / miner.duration.Seconds()
+	}
+	miner.mutex.Unlock()
+
+	last, err := repo.LastBlock()
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if last != nil {
+		status.Height = last.Header.Height + 1
+	}
+
+	if miner.required(last) {
+		if status.Target, err = miner.nextTarget(last); err != nil {
+			return nil, err
+		}
+		status.Difficulty = model.Difficulty(status.Target)
+	}
+
+	return status, nil
+}
+
+// required returns true if the block following last is sealed with proof of work: proof of work is
+// enabled, or it started before, since the repository does not save a block without a target after a
+// block with a target.
+func (p *proofOfWork) required(last *model.Block) bool {
+	return p.enabled || last != nil && last.Header.Target != ""
+}
+
+// seal sets the target of the block following last and mines its nonce. It returns ErrMiningStopped
+// when stop is closed first.
+func (p *proofOfWork) seal(block *model.Block, last *model.Block, stop <-chan bool) error {
+	target, err := p.nextTarget(last)
+	if err != nil {
+		return err
+	}
+
+	block.Header.Target = target
+	if !p.mine(&block.Header, stop) {
+		return ErrMiningStopped
+	}
+	block.Hash = block.Header.Hash()
+
+	return nil
+}
+
+// nextTarget returns the target of the block following last. The first block with proof of work has the
+// initial target. The target of the first block of a window is adjusted by the time the previous window
+// took, the other blocks have the target of the previous block.
+func (p *proofOfWork) nextTarget(last *model.Block) (string, error) {
+	if last == nil || last.Header.Target == "" {
+		return model.TargetFromBits(p.initialBits), nil
+	}
+
+	height := last.Header.Height + 1
+	if p.window < 1 || height%p.window != 0 || height <= p.window {
+		return last.Header.Target, nil
+	}
+
+	first, err := repo.GetBlock(last.Header.Height - p.window)
+	if err != nil {
+		return "", storageError(err)
+	}
+
+	// The previous window started before proof of work
+	if first == nil || first.Header.Target == "" {
+		return last.Header.Target, nil
+	}
+
+	actual := last.Header.Timestamp.Sub(first.Header.Timestamp)
+	return model.Retarget(last.Header.Target, actual, time.Duration(p.window)*p.blockTime)
+}
+
+// mine searches the nonce of the header with all the threads, thread i tries the nonces i, i + threads, ...
+// The nonce is the last field of the encoding, so only the last 8 bytes are changed. It returns false if
+// stop was closed before the nonce was found, the threads check it every stopCheckInterval hashes.
+func (p *proofOfWork) mine(header *model.BlockHeader, stop <-chan bool) bool {
+	target, _ := hex.DecodeString(header.Target)
+	data := header.Serialize()
+	prefix := data[:len(data)-8]
+
+	p.mutex.Lock()
+	p.mining = true
+	p.mutex.Unlock()
+
+	start := time.Now()
+	var found, stopped int32
+	var nonce, hashes uint64
+	var wg sync.WaitGroup
+
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			atomic.StoreInt32(&stopped, 1)
+		case <-done:
+		}
+	}()
+
+	for i := 0; i < p.threads; i++ {
+		wg.Add(1)
+		go func(n uint64) {
+			defer wg.Done()
+
+			buffer := make([]byte, len(data))
+			copy(buffer, prefix)
+
+			var count uint64
+			for ; atomic.LoadInt32(&found) == 0; n += uint64(p.threads) {
+				if count%stopCheckInterval == 0 && atomic.LoadInt32(&stopped) == 1 {
+					break
+				}
+
+				binary.BigEndian.PutUint64(buffer[len(prefix):], n)
+				hash := sha256.Sum256(buffer)
+				count++
+
+				if bytes.Compare(hash[:], target) <= 0 {
+					if atomic.CompareAndSwapInt32(&found, 0, 1) {
+						nonce = n
+					}
+					break
+				}
+			}
+
+			atomic.AddUint64(&hashes, count)
+		}(uint64(i))
+	}
+
+	wg.Wait()
+	header.Nonce = nonce
+
+	p.mutex.Lock()
+	p.mining = false
+	p.hashes += hashes
+	p.duration += time.Since(start)
+	if found == 1 {
+		p.blocks++
+	}
+	p.mutex.Unlock()
+
+	return found == 1
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"cryptocoin-server/client"
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"testing"
+	"time"
+)
+
+// setMiner enables proof of work with an easy target and returns a function which restores the miner.
+func setMiner(initialBits int, window int64, blockTime time.Duration) func() {
+	previous := miner
+
+	c := config.InitConfig()
+	c.ProofOfWork = true
+	c.MiningThreads = 2
+	c.MiningInitialBits = initialBits
+	c.MiningRetargetWindow = window
+	c.MiningBlockTime = blockTime
+	miner = newProofOfWork(c)
+
+	return func() { miner = previous }
+}
+
+func TestMiningBlocks(t *testing.T) {
+	defer setMiner(8, 10, time.Second)()
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+
+	block, err := ConfirmPending()
+	if block == nil || err != nil {
+		t.Fatal("ConfirmPending failed:", block, err)
+	}
+
+	if block.Header.Target != model.TargetFromBits(8) || !block.Header.MeetsTarget() || !block.Valid() {
+		t.Error("Block was not mined:", block)
+	}
+
+	if saved, _ := GetBlock(1); saved == nil || saved.Hash != block.Hash || saved.Header.Nonce != block.Header.Nonce {
+		t.Error("Mined block was not saved:", saved)
+	}
+
+	// The proof of a mined block meets the minimum difficulty of the target, not a harder one
+	key, _ := ecdsa.ParsePrivKey(operatorPrivKey)
+	operator := ecdsa.ExportPubKey(&key.PublicKey)
+	proof, _ := GetTransactionProof(first.TxID)
+	if err := client.VerifyTransactionProof(proof, first.TxID, operator, model.Difficulty(model.TargetFromBits(8))); err != nil {
+		t.Error("Proof of a mined block not verified:", err)
+	}
+
+	if err := client.VerifyTransactionProof(proof, first.TxID, operator, model.Difficulty(model.TargetFromBits(9))); err == nil {
+		t.Error("Proof of a block easier than the minimum difficulty verified")
+	}
+
+	status, err := GetMiningStatus()
+	if !status.Enabled || status.Threads != 2 || status.BlocksMined != 2 || status.Hashes == 0 || status.Height != 2 || status.Target != block.Header.Target || err != nil {
+		t.Error("Mining status does not match:", status, err)
+	}
+}
+
+func TestMiningAfterDisabled(t *testing.T) {
+	restore := setMiner(4, 10, time.Second)
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+
+	// Proof of work started, so the blocks are still mined when it is disabled
+	restore()
+	TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+
+	block, err := ConfirmPending()
+	if block == nil || block.Header.Target != model.TargetFromBits(4) || !block.Header.MeetsTarget() || err != nil {
+		t.Error("Block was not mined:", block, err)
+	}
+
+	if status, err := GetMiningStatus(); status.Enabled || status.Target != model.TargetFromBits(4) || err != nil {
+		t.Error("Mining status does not match:", status, err)
+	}
+}
+
+func TestMiningFullMempool(t *testing.T) {
+	defer setMiner(4, 10, time.Second)()
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(1)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+
+	// The full mempool is not confirmed by AddTransactions, StartMempool mines the block
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	if status, _ := GetTransactionStatus(first.TxID); status.Status != model.StatusPending {
+		t.Error("Transaction was confirmed by AddTransactions:", status)
+	}
+
+	stop := StartMempool(time.Hour)
+	for i := 0; i < 100 && pool.size() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+
+	if status, _ := GetTransactionStatus(first.TxID); status.Status != model.StatusConfirmed {
+		t.Error("Full mempool was not confirmed:", status)
+	}
+}
+
+func TestMiningRetarget(t *testing.T) {
+	defer setMiner(8, 2, time.Minute)()
+	InitService(repository.NewMemoryRepository())
+
+	// Blocks of the first window took half the block time
+	start := time.Now().Add(-time.Hour)
+	var last *model.Block
+	for i := 0; i < 3; i++ {
+		block := model.NewBlock(last, []string{string(rune('a' + i))})
+		block.Header.Timestamp = start.Add(time.Duration(i) * time.Minute / 2)
+		block.Header.Target = model.TargetFromBits(8)
+		miner.mine(&block.Header, nil)
+		block.Hash = block.Header.Hash()
+		repo.SaveBlock(block, nil)
+		last = block
+	}
+
+	// Height 3 is in the same window, height 4 starts a new one
+	if target, err := miner.nextTarget(last); target != model.TargetFromBits(8) || err != nil {
+		t.Error("Target changed within the window:", target, err)
+	}
+
+	block := model.NewBlock(last, []string{"d"})
+	block.Header.Target = last.Header.Target
+	block.Header.Timestamp = start.Add(3 * time.Minute / 2)
+	miner.mine(&block.Header, nil)
+	block.Hash = block.Header.Hash()
+	repo.SaveBlock(block, nil)
+
+	target, err := miner.nextTarget(block)
+	if difficulty := model.Difficulty(target) / model.Difficulty(model.TargetFromBits(9)); difficulty < 0.99 || difficulty > 1.01 || err != nil {
+		t.Error("Target was not retargeted:", target, err)
+	}
+}
+
+func TestMiningStopped(t *testing.T) {
+	defer setMiner(64, 10, time.Second)()
+	InitService(repository.NewMemoryRepository())
+
+	stop := make(chan bool)
+	sealed := make(chan error)
+	go func() { sealed <- miner.seal(model.NewBlock(nil, []string{"a"}), nil, stop) }()
+
+	close(stop)
+	select {
+	case err := <-sealed:
+		if err != ErrMiningStopped {
+			t.Error("ErrMiningStopped not returned:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Mining was not stopped")
+	}
+
+	if status, _ := GetMiningStatus(); status.Mining || status.BlocksMined != 0 {
+		t.Error("Mining status does not match:", status)
+	}
+}
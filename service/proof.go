@@ -0,0 +1,72 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrNotInBlock is returned when a transaction exists but is not in a block: it is pending, or it is a
+// genesis transaction saved before genesis transactions were sealed into blocks.
+var ErrNotInBlock = errors.New("Transaction is not in a block")
+
+// operatorPrivKey signs the blocks of the transaction proofs and the tree heads of the log.
+var operatorPrivKey = config.InitConfig().OperatorPrivKey
+
+// GetTransactionProof returns the proof that the transaction is in a block: the audit path of the TxID to
+// the Merkle root of the block, and the block header with its hash signed by the operator.
+func GetTransactionProof(txID string) (*model.TransactionProof, error) {
+	block, err := repo.GetBlockByTxID(txID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if block == nil {
+		if _, err := GetTransaction(txID); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotInBlock
+	}
+
+	proof := &model.TransactionProof{TxID: txID, TreeSize: len(block.TxIDs), BlockHash: block.Hash, Header: block.Header}
+
+	leaves := make([][]byte, len(block.TxIDs))
+	for i, blockTxID := range block.TxIDs {
+		leaves[i] = []byte(blockTxID)
+		if blockTxID == txID {
+			proof.Index = i
+		}
+	}
+
+	proof.Path = hexHashes(model.MerklePath(leaves, proof.Index))
+
+	hash, err := hex.DecodeString(block.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	proof.PubKey, proof.Signature, err = signOperator(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// signOperator signs the hash with the operator key, it returns the public key of the operator and the
+// signature.
+func signOperator(hash []byte) (string, string, error) {
+	key, err := ecdsa.ParsePrivKey(operatorPrivKey)
+	if err != nil {
+		return "", "", errors.New("Operator key is not valid")
+	}
+
+	signature, err := ecdsa.Sign(key, hash)
+	if err != nil {
+		return "", "", err
+	}
+
+	return ecdsa.ExportPubKey(&key.PublicKey), signature, nil
+}
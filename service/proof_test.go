@@ -0,0 +1,43 @@
+package service
+
+import (
+	"cryptocoin-server/client"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"testing"
+)
+
+func TestGetTransactionProof(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	second, _ := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+
+	if _, err := GetTransactionProof(first.TxID); err != ErrNotInBlock || ErrorCode(err) != CodeNotInBlock {
+		t.Error("Proof of a pending transaction returned:", err)
+	}
+
+	ConfirmPending()
+
+	key, _ := ecdsa.ParsePrivKey(operatorPrivKey)
+	operator := ecdsa.ExportPubKey(&key.PublicKey)
+
+	for _, txID := range []string{genesis.TxID, first.TxID, second.TxID} {
+		proof, err := GetTransactionProof(txID)
+		if err != nil {
+			t.Fatal("GetTransactionProof failed:", err)
+		}
+
+		if err := client.VerifyTransactionProof(proof, txID, operator, 0); err != nil {
+			t.Error("Proof not verified:", txID, err)
+		}
+	}
+
+	if _, err := GetTransactionProof("unknown"); err != ErrNotFound {
+		t.Error("ErrNotFound not returned:", err)
+	}
+}
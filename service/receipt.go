@@ -0,0 +1,44 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"log"
+)
+
+// signReceipts signs the receipts with the operator key. A receipt without a TxID is not signed, it is nil.
+// The transactions are accepted even if the receipts can not be signed, so the receipts are nil then.
+func signReceipts(receipts []model.Receipt) []*model.Receipt {
+	results := make([]*model.Receipt, len(receipts))
+
+	for i := range receipts {
+		if receipts[i].TxID == "" {
+			continue
+		}
+
+		receipt := receipts[i]
+		var err error
+		receipt.PubKey, receipt.Signature, err = signOperator(receipt.Hash())
+		if err != nil {
+			log.Print(err)
+			return make([]*model.Receipt, len(receipts))
+		}
+
+		results[i] = &receipt
+	}
+
+	return results
+}
+
+// acceptanceReceipts returns the unsigned receipts of the pending or confirmed transactions, from their
+// acceptance. The receipt of a transaction saved before the acceptance was kept is empty.
+func acceptanceReceipts(transactions []model.Transaction) []model.Receipt {
+	receipts := make([]model.Receipt, len(transactions))
+
+	for i, t := range transactions {
+		if t.Acceptance != nil {
+			receipts[i] = model.Receipt{TxID: t.TxID, AcceptedAt: t.Acceptance.AcceptedAt, Sequence: t.Acceptance.Sequence}
+		}
+	}
+
+	return receipts
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"cryptocoin-server/client"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"testing"
+	"time"
+)
+
+func TestSubmitTransactionsReceipts(t *testing.T) {
+	defer setIdempotencyStore(time.Hour)()
+	defer func(batchSize int) { mempoolBatchSize = batchSize }(mempoolBatchSize)
+	mempoolBatchSize = 10
+
+	r := repository.NewMemoryRepository()
+	InitService(r)
+	wallet, _ := model.NewWallet()
+
+	key, _ := ecdsa.ParsePrivKey(operatorPrivKey)
+	operator := ecdsa.ExportPubKey(&key.PublicKey)
+
+	// The genesis transaction is accepted first
+	genesis, _ := CreateGenesisTransaction()
+	if genesis.Acceptance == nil || genesis.Acceptance.Sequence != 1 {
+		t.Error("Genesis acceptance does not match:", genesis.Acceptance)
+	}
+
+	payment := newPayment(genesis, wallet.PubKey, 100)
+	saved, receipts, _, err := SubmitTransactions("key", []model.Transaction{payment})
+	if len(receipts) != 1 || receipts[0] == nil || err != nil {
+		t.Fatal("Receipt not returned:", receipts, err)
+	}
+
+	receipt := receipts[0]
+	if err := client.VerifyReceipt(receipt, saved[0].TxID, operator); err != nil {
+		t.Error("Receipt not verified:", err)
+	}
+
+	if receipt.Sequence != 2 || time.Since(receipt.AcceptedAt) > time.Minute {
+		t.Error("Receipt does not match:", receipt)
+	}
+
+	// A replay returns the same receipt
+	if _, again, _, err := SubmitTransactions("key", []model.Transaction{payment}); len(again) != 1 || again[0] != receipt || err != nil {
+		t.Error("Receipt of the replay does not match:", again, err)
+	}
+
+	// Without a key, the receipt has the same acceptance, before and after the transaction is confirmed
+	for _, confirm := range []bool{false, true} {
+		if confirm {
+			ConfirmPending()
+		}
+
+		_, replay, replayed, err := SubmitTransactions("", []model.Transaction{payment})
+		if !replayed || len(replay) != 1 || replay[0] == nil || replay[0].Sequence != 2 || !replay[0].AcceptedAt.Equal(receipt.AcceptedAt) || err != nil {
+			t.Fatal("Receipt of the replay does not match:", confirm, replay, err)
+		}
+
+		if err := client.VerifyReceipt(replay[0], saved[0].TxID, operator); err != nil {
+			t.Error("Receipt of the replay not verified:", confirm, err)
+		}
+	}
+
+	// A pending transaction is lost when the server restarts, its sequence number is not used again
+	lost, _ := TransferFromGenesisAccount(saved[0].TxID, wallet.PubKey, 100)
+	InitService(r)
+
+	restarted, _ := CreateGenesisTransaction()
+	if restarted.Acceptance == nil || restarted.Acceptance.Sequence <= lost.Acceptance.Sequence {
+		t.Error("Sequence number was used again:", restarted.Acceptance, lost.Acceptance)
+	}
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/util/ecdsa"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Codes of the consensus rules, a transaction failing a rule is rejected with a RuleError with its code.
+const (
+	CodeNoInputsOrOutputs        = "no_inputs_or_outputs"
+	CodeCollectsFees             = "collects_fees"
+	CodeDuplicateInput           = "duplicate_input"
+	CodeInvalidSignature         = "invalid_signature"
+	CodePreviousNotFound         = "previous_not_found"
+	CodeInvalidPreviousSignature = "invalid_previous_signature"
+	CodeOwnerMismatch            = "owner_mismatch"
+	CodeTimestampBeforePrevious  = "timestamp_before_previous"
+	CodeTimestampInFuture        = "timestamp_in_future"
+	CodeInvalidAddress           = "invalid_address"
+	CodeValueNotPositive         = "value_not_positive"
+	CodeValueOverflow            = "value_overflow"
+	CodeOutputsExceedInputs      = "outputs_exceed_inputs"
+	CodeFeeTooLow                = "fee_too_low"
+	CodeNoOperatorAddress        = "no_operator_address"
+)
+
+// RuleError is the consensus rule a transaction failed.
+type RuleError struct {
+	Code    string
+	Message string
+}
+
+func (e *RuleError) Error() string {
+	return e.Message
+}
+
+// verification is the state of the verification of a transaction, shared by the rules.
+type verification struct {
+	t                *model.Transaction
+	prevTransactions map[model.Outpoint]*model.Transaction
+	inputValue       int64 // Set by checkValueOverflow
+	outputValue      int64 // Set by checkValueOverflow
+}
+
+// rule is a consensus rule. The rules are checked in order, a rule is only checked if the rules it
+// requires passed.
+type rule struct {
+	code     string
+	check    func(v *verification) error
+	requires []string
+}
+
+var rules = []rule{
+	{CodeNoInputsOrOutputs, checkInputsOutputs, nil},
+	{CodeCollectsFees, checkCollects, nil},
+	{CodeDuplicateInput, checkDuplicateInputs, nil},
+	{CodeInvalidSignature, checkSignature, nil},
+	{CodePreviousNotFound, checkPreviousFound, nil},
+	{CodeInvalidPreviousSignature, checkPreviousSignatures, []string{CodePreviousNotFound}},
+	{CodeOwnerMismatch, checkOwners, []string{CodePreviousNotFound}},
+	{CodeTimestampBeforePrevious, checkPreviousTimestamps, []string{CodePreviousNotFound}},
+	{CodeTimestampInFuture, checkTimestamp, nil},
+	{CodeInvalidAddress, checkAddresses, nil},
+	{CodeValueNotPositive, checkValues, nil},
+	{CodeValueOverflow, checkValueOverflow, []string{CodePreviousNotFound}},
+	{CodeOutputsExceedInputs, checkBalance, []string{CodeValueOverflow}},
+	{CodeFeeTooLow, checkMinimumFee, []string{CodeOutputsExceedInputs}},
+	{CodeNoOperatorAddress, checkOperatorAddress, []string{CodeOutputsExceedInputs}},
+}
+
+// CheckRules checks all the consensus rules for the transaction and returns the result of every rule.
+// A rule is skipped if a rule it requires did not pass. prevTransactions are the transactions of the
+// outputs spent by the inputs.
+func CheckRules(t *model.Transaction, prevTransactions map[model.Outpoint]*model.Transaction) []model.RuleResult {
+	v := &verification{t: t, prevTransactions: prevTransactions}
+	results := make([]model.RuleResult, 0, len(rules))
+	passed := make(map[string]bool)
+
+	for _, rule := range rules {
+		result := model.RuleResult{Code: rule.code, Status: model.RulePassed}
+
+		for _, code := range rule.requires {
+			if !passed[code] {
+				result.Status = model.RuleSkipped
+			}
+		}
+
+		if result.Status == model.RulePassed {
+			if err := rule.check(v); err != nil {
+				result.Status = model.RuleFailed
+				result.Message = err.Error()
+			}
+		}
+
+		passed[rule.code] = result.Status == model.RulePassed
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// checkInputsOutputs verifies the transaction spends and sends value.
+func checkInputsOutputs(v *verification) error {
+	if len(v.t.Inputs) == 0 || len(v.t.Outputs) == 0 {
+		return errors.New("Transaction must have inputs and outputs")
+	}
+	return nil
+}
+
+// checkCollects verifies the transaction is not a fee transaction, only the server creates them.
+func checkCollects(v *verification) error {
+	if len(v.t.Collects) > 0 {
+		return errors.New("Transaction must not collect fees")
+	}
+	return nil
+}
+
+// checkDuplicateInputs verifies the transaction does not spend an output twice.
+func checkDuplicateInputs(v *verification) error {
+	spent := make(map[model.Outpoint]bool)
+	for _, input := range v.t.Inputs {
+		if spent[input.Outpoint] {
+			return fmt.Errorf("Output %s must not be spent twice", input.Outpoint)
+		}
+		spent[input.Outpoint] = true
+	}
+	return nil
+}
+
+// checkSignature verifies the signatures of all the inputs, the legacy hash of a record is only accepted
+// until config.LegacySignaturesUntil.
+func checkSignature(v *verification) error {
+	if verified, _ := verifySignature(v.t, time.Now()); !verified {
+		return errors.New("Signatures must be valid for all inputs")
+	}
+	return nil
+}
+
+// checkPreviousFound verifies the previous transaction of every input was found.
+func checkPreviousFound(v *verification) error {
+	for _, input := range v.t.Inputs {
+		if pt := v.prevTransactions[input.Outpoint]; pt == nil || pt.Output(input.Outpoint) == nil {
+			return errors.New("Previous transaction not found or is invalid")
+		}
+	}
+	return nil
+}
+
+// checkPreviousSignatures verifies the signatures of the previous transactions. The signature of a
+// transaction the repository saved in seconds may not verify anymore (see
+// model.Transaction.TimestampInSeconds), it was verified when the transaction was accepted.
+func checkPreviousSignatures(v *verification) error {
+	for _, input := range v.t.Inputs {
+		pt := v.prevTransactions[input.Outpoint]
+		if verified, _ := VerifySignature(pt); !verified && !pt.TimestampInSeconds {
+			return errors.New("Signatures must be valid for both transactions")
+		}
+	}
+	return nil
+}
+
+// checkOwners verifies every input is spent by the owner of the output.
+func checkOwners(v *verification) error {
+	for _, input := range v.t.Inputs {
+		if v.prevTransactions[input.Outpoint].Output(input.Outpoint).ToAddress != input.PubKey {
+			return errors.New("Owner must match previous transaction")
+		}
+	}
+	return nil
+}
+
+// checkPreviousTimestamps verifies the transaction is not older than the previous transactions.
+func checkPreviousTimestamps(v *verification) error {
+	for _, input := range v.t.Inputs {
+		if v.prevTransactions[input.Outpoint].Timestamp.After(v.t.Timestamp) {
+			return errors.New("Timestamp must be after previous transaction")
+		}
+	}
+	return nil
+}
+
+// checkTimestamp verifies the timestamp is not in the future.
+func checkTimestamp(v *verification) error {
+	if v.t.Timestamp.After(time.Now()) {
+		return errors.New("Timestamp must be less than current time")
+	}
+	return nil
+}
+
+// checkAddresses verifies every output is sent to a valid public key.
+func checkAddresses(v *verification) error {
+	for _, output := range v.t.Outputs {
+		if _, err := ecdsa.ParsePubKey(output.ToAddress); err != nil {
+			return errors.New("SendTo must be a valid Public Key")
+		}
+	}
+	return nil
+}
+
+// checkValues verifies every output sends value.
+func checkValues(v *verification) error {
+	for _, output := range v.t.Outputs {
+		if output.Value <= 0 {
+			return errors.New("Output value must be positive")
+		}
+	}
+	return nil
+}
+
+// checkValueOverflow sums the values of the inputs and of the outputs, which must not overflow.
+func checkValueOverflow(v *verification) error {
+	var err error
+	v.inputValue, v.outputValue = 0, 0
+
+	for _, input := range v.t.Inputs {
+		if v.inputValue, err = addValue(v.inputValue, v.prevTransactions[input.Outpoint].Output(input.Outpoint).Value); err != nil {
+			return err
+		}
+	}
+
+	for _, output := range v.t.Outputs {
+		if v.outputValue, err = addValue(v.outputValue, output.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkBalance verifies the outputs do not send more than the inputs, the rest is the fee.
+func checkBalance(v *verification) error {
+	if v.outputValue > v.inputValue {
+		return errors.New("Total value of the outputs must not be greater than the inputs")
+	}
+	return nil
+}
+
+// checkMinimumFee verifies the fee is at least the minimum fee of the policy.
+func checkMinimumFee(v *verification) error {
+	size, err := v.t.Size()
+	if err != nil {
+		return err
+	}
+
+	if minimum := feePolicy.MinimumFee(size, len(v.t.Inputs)); v.inputValue-v.outputValue < minimum {
+		return fmt.Errorf("Fee must be at least %d", minimum)
+	}
+	return nil
+}
+
+// checkOperatorAddress verifies fees are only paid when there is an operator to send them to.
+func checkOperatorAddress(v *verification) error {
+	if v.inputValue > v.outputValue && feePolicy.OperatorAddress == "" {
+		return errors.New("Fees are not accepted without an operator address")
+	}
+	return nil
+}
+
+// addValue adds the value to the sum, it returns an error if the sum overflows.
+func addValue(sum int64, value int64) (int64, error) {
+	if (value > 0 && sum > math.MaxInt64-value) || (value < 0 && sum < math.MinInt64-value) {
+		return 0, errors.New("Total value must not overflow")
+	}
+	return sum + value, nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// ruleFixture is a valid transaction spending the outputs of a genesis transaction.
+type ruleFixture struct {
+	owner, other     *model.Wallet
+	pt, nt           *model.Transaction
+	prevTransactions map[model.Outpoint]*model.Transaction
+}
+
+func newRuleFixture(values ...int64) *ruleFixture {
+	f := new(ruleFixture)
+	f.owner, _ = model.NewWallet()
+	f.other, _ = model.NewWallet()
+
+	f.pt = &model.Transaction{Timestamp: time.Now().Add(-time.Minute)}
+	for _, value := range values {
+		f.pt.Outputs = append(f.pt.Outputs, model.Output{ToAddress: f.owner.PubKey, Value: value})
+	}
+	f.pt.TxID, _ = f.pt.ID()
+
+	f.prevTransactions = make(map[model.Outpoint]*model.Transaction)
+	for i := range f.pt.Outputs {
+		f.prevTransactions[f.pt.Outpoint(i)] = f.pt
+	}
+
+	f.nt = &model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: f.pt.Outpoint(0), PubKey: f.owner.PubKey}},
+		Outputs:   []model.Output{{ToAddress: f.other.PubKey, Value: 60}, {ToAddress: f.owner.PubKey, Value: 40}},
+	}
+	SignInputs(f.nt, f.owner.PrivKey)
+
+	return f
+}
+
+// invalidTransactions is the negative corpus: every transaction fails exactly the rule of the code.
+var invalidTransactions = []struct {
+	name   string
+	code   string
+	policy *FeePolicy
+	mutate func(f *ruleFixture) // Changes the valid transaction of the fixture, the owner signs it again
+}{
+	{"no inputs", CodeNoInputsOrOutputs, nil, func(f *ruleFixture) { f.nt.Inputs = nil }},
+	{"no outputs", CodeNoInputsOrOutputs, nil, func(f *ruleFixture) { f.nt.Outputs = nil }},
+	{"collects fees", CodeCollectsFees, nil, func(f *ruleFixture) { f.nt.Collects = []string{f.pt.TxID} }},
+	{"input spent twice", CodeDuplicateInput, nil, func(f *ruleFixture) { f.nt.Inputs = append(f.nt.Inputs, f.nt.Inputs[0]) }},
+	{"signed by another key", CodeInvalidSignature, nil, func(f *ruleFixture) { f.owner = f.other }},
+	{"invalid public key", CodeInvalidSignature, nil, func(f *ruleFixture) { f.nt.Inputs[0].PubKey = "AAAA" }},
+	{"previous not found", CodePreviousNotFound, nil, func(f *ruleFixture) { f.prevTransactions = nil }},
+	{"output index out of range", CodePreviousNotFound, nil, func(f *ruleFixture) {
+		f.nt.Inputs[0].Outpoint.Index = 1
+		f.prevTransactions[f.nt.Inputs[0].Outpoint] = f.pt
+	}},
+	{"previous not signed", CodeInvalidPreviousSignature, nil, func(f *ruleFixture) {
+		f.pt.Inputs = []model.Input{{Outpoint: model.Outpoint{TxID: "a"}, PubKey: f.owner.PubKey}}
+	}},
+	{"previous in whole seconds not signed", CodeInvalidPreviousSignature, nil, func(f *ruleFixture) {
+		f.pt.Inputs = []model.Input{{Outpoint: model.Outpoint{TxID: "a"}, PubKey: f.owner.PubKey}}
+		f.pt.Timestamp = f.pt.Timestamp.Truncate(time.Second)
+	}},
+	{"spent by another key", CodeOwnerMismatch, nil, func(f *ruleFixture) {
+		f.nt.Inputs[0].PubKey = f.other.PubKey
+		f.owner = f.other
+	}},
+	{"before previous", CodeTimestampBeforePrevious, nil, func(f *ruleFixture) { f.nt.Timestamp = f.pt.Timestamp.Add(-time.Nanosecond) }},
+	{"in the future", CodeTimestampInFuture, nil, func(f *ruleFixture) { f.nt.Timestamp = time.Now().Add(time.Hour) }},
+	{"empty address", CodeInvalidAddress, nil, func(f *ruleFixture) { f.nt.Outputs[0].ToAddress = "" }},
+	{"short address", CodeInvalidAddress, nil, func(f *ruleFixture) { f.nt.Outputs[0].ToAddress = f.other.PubKey[:84] }},
+	{"address off the curve", CodeInvalidAddress, nil, func(f *ruleFixture) {
+		f.nt.Outputs[0].ToAddress = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=="
+	}},
+	{"zero value", CodeValueNotPositive, nil, func(f *ruleFixture) { f.nt.Outputs[1].Value = 0 }},
+	{"negative value", CodeValueNotPositive, nil, func(f *ruleFixture) {
+		f.nt.Outputs[0].Value = 110
+		f.nt.Outputs[1].Value = -10
+	}},
+	{"outputs overflow", CodeValueOverflow, nil, func(f *ruleFixture) { f.nt.Outputs[0].Value = math.MaxInt64 }},
+	{"inputs overflow", CodeValueOverflow, nil, func(f *ruleFixture) {
+		f.pt.Outputs = []model.Output{{ToAddress: f.owner.PubKey, Value: math.MaxInt64}, {ToAddress: f.owner.PubKey, Value: math.MaxInt64}}
+		f.prevTransactions[f.pt.Outpoint(1)] = f.pt
+		f.nt.Inputs = append(f.nt.Inputs, model.Input{Outpoint: f.pt.Outpoint(1), PubKey: f.owner.PubKey})
+	}},
+	{"outputs exceed inputs", CodeOutputsExceedInputs, nil, func(f *ruleFixture) { f.nt.Outputs[1].Value = 41 }},
+	{"fee too low", CodeFeeTooLow, &FeePolicy{PerInput: 1, OperatorAddress: "x"}, func(f *ruleFixture) {}},
+	{"fee without operator", CodeNoOperatorAddress, &FeePolicy{}, func(f *ruleFixture) { f.nt.Outputs[1].Value = 39 }},
+}
+
+func TestVerifyTransactionRules(t *testing.T) {
+	for _, c := range invalidTransactions {
+		f := newRuleFixture(100)
+		c.mutate(f)
+		SignInputs(f.nt, f.owner.PrivKey)
+
+		if c.policy != nil {
+			previous := feePolicy
+			feePolicy = c.policy
+			_, err := VerifyTransaction(f.nt, f.prevTransactions)
+			feePolicy = previous
+
+			checkRuleError(t, c.name, c.code, err)
+			continue
+		}
+
+		verified, err := VerifyTransaction(f.nt, f.prevTransactions)
+		if verified {
+			t.Error("Invalid transaction was verified:", c.name)
+		}
+		checkRuleError(t, c.name, c.code, err)
+	}
+}
+
+func checkRuleError(t *testing.T, name string, code string, err error) {
+	if ruleErr, ok := err.(*RuleError); !ok || ruleErr.Code != code {
+		t.Error("Rule error does not match:", name, code, err)
+	}
+}
+
+func TestVerifyTransactionRuleCodes(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, c := range invalidTransactions {
+		covered[c.code] = true
+	}
+
+	codes := make(map[string]bool)
+	for _, rule := range rules {
+		if codes[rule.code] {
+			t.Error("Rule code is not distinct:", rule.code)
+		}
+		codes[rule.code] = true
+
+		if !covered[rule.code] {
+			t.Error("Rule is not covered by the negative corpus:", rule.code)
+		}
+	}
+}
+
+func FuzzVerifyTransactionValues(f *testing.F) {
+	f.Add(int64(60), int64(40), 0)
+	f.Add(int64(0), int64(100), 0)
+	f.Add(int64(-1), int64(101), 0)
+	f.Add(int64(math.MaxInt64), int64(1), 1)
+	f.Add(int64(math.MaxInt64-100), int64(100), 1)
+	f.Add(int64(1), int64(1), 2)
+
+	fixture := newRuleFixture(100, math.MaxInt64)
+
+	f.Fuzz(func(t *testing.T, value0 int64, value1 int64, index int) {
+		nt := model.Transaction{
+			Timestamp: time.Now(),
+			Inputs:    []model.Input{{Outpoint: fixture.pt.Outpoint(index), PubKey: fixture.owner.PubKey}},
+			Outputs:   []model.Output{{ToAddress: fixture.other.PubKey, Value: value0}, {ToAddress: fixture.owner.PubKey, Value: value1}},
+		}
+		SignInputs(&nt, fixture.owner.PrivKey)
+
+		verified, err := VerifyTransaction(&nt, fixture.prevTransactions)
+		if verified != (err == nil) {
+			t.Fatal("Result does not match the error:", verified, err)
+		}
+
+		if !verified {
+			return
+		}
+
+		// The outputs of a valid transaction are positive and do not send more than the input
+		input := fixture.pt.Output(nt.Inputs[0].Outpoint)
+		outputs := new(big.Int).Add(big.NewInt(value0), big.NewInt(value1))
+		if input == nil || value0 <= 0 || value1 <= 0 || outputs.Cmp(big.NewInt(input.Value)) > 0 {
+			t.Error("Invalid transaction was verified:", nt)
+		}
+	})
+}
+
+func FuzzVerifyTransactionJSON(f *testing.F) {
+	fixture := newRuleFixture(100)
+	data, _ := json.Marshal(fixture.nt)
+	f.Add(data)
+	f.Add([]byte(`{"inputs":[{"outpoint":{"txId":"a","index":-1}}],"outputs":[{"value":1}]}`))
+	f.Add([]byte(`{"inputs":[{}],"outputs":[{}],"collects":["a"]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var nt model.Transaction
+		if err := json.Unmarshal(data, &nt); err != nil {
+			return
+		}
+
+		// Must not panic, and only the fixture may verify
+		verified, err := VerifyTransaction(&nt, fixture.prevTransactions)
+		if verified != (err == nil) {
+			t.Fatal("Result does not match the error:", verified, err)
+		}
+
+		if _, ok := err.(*RuleError); err != nil && !ok {
+			t.Error("Error is not a rule error:", err)
+		}
+	})
+}
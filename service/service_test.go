@@ -0,0 +1,14 @@
+package service
+
+import (
+	"cryptocoin-server/repository"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Transactions are confirmed as soon as they are added, unless a test creates another mempool
+	mempoolBatchSize = 1
+	InitService(repository.NewMemoryRepository())
+	os.Exit(m.Run())
+}
@@ -6,136 +6,271 @@ import (
 	"cryptocoin-server/repository"
 	"cryptocoin-server/util/ecdsa"
 	"errors"
+	"fmt"
+	"log"
 	"time"
 )
 
-// AddTransactions adds new transactions to the ledger. All transactions must be valid.
-func AddTransactions(transactions []model.Transaction) error {
-	prevTransactions := make(map[string]*model.Transaction)
+var repo repository.Repository
+var spendLocks = newKeyLocks()
 
-	// Get previous transaction for each new transaction
-	for _, t := range transactions {
-		if _, contains := prevTransactions[t.PrevSignature]; !contains {
-			pt, err := repository.GetTransaction(t.PrevSignature, false) // TODO: Get all previous transactions in one call
+// InitService sets the repository used by the service, with an empty mempool.
+func InitService(r repository.Repository) {
+	repo = r
+	pool = newMempool(mempoolBatchSize)
+	txLog = newTransparencyLog()
+}
 
-			if err != nil {
-				return err
-			}
+// GetTransactions returns a page of the transactions selected by the filter and the cursor of the
+// next page, which is empty on the last page.
+func GetTransactions(filter repository.Filter) ([]model.Transaction, string, error) {
+	limit := filter.Limit
 
-			if pt != nil {
-				prevTransactions[pt.Signature] = pt
-			}
-		}
+	// Get one more transaction to know if there is a next page
+	filter.Limit++
+	transactions, err := repo.GetTransactions(filter)
+	if err != nil {
+		return nil, "", storageError(err)
+	}
+
+	if len(transactions) <= limit {
+		return transactions, "", nil
+	}
+
+	return transactions[:limit], repository.NewCursor(&transactions[limit-1]).String(), nil
+}
+
+// GetTransaction returns transaction by TxID, pending or confirmed, or ErrNotFound if it does not exist.
+func GetTransaction(txID string) (*model.Transaction, error) {
+	if t, pending := pool.get(txID); pending {
+		return t, nil
 	}
 
-	timestamp := transactions[0].Timestamp
+	t, err := repo.GetTransaction(txID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if t == nil {
+		return nil, ErrNotFound
+	}
+
+	return t, nil
+}
+
+// AddTransactions adds new transactions to the mempool, they are pending until they are confirmed in a
+// batch. All transactions must be valid, they may spend outputs of pending transactions. The fees paid by
+// the transactions are sent to the operator address by a fee transaction added with them. The error of a
+// rejected transaction is a *BatchError with its index.
+func AddTransactions(transactions []model.Transaction) error {
+	_, err := addTransactions(transactions)
+	return err
+}
 
-	// Verify all the transactions and calculate new balance
-	for _, t := range transactions {
-		verified, err := VerifyTransaction(&t, prevTransactions[t.PrevSignature])
+// addTransactions adds new transactions like AddTransactions, and returns their unsigned receipts.
+func addTransactions(transactions []model.Transaction) ([]model.Receipt, error) {
+	if len(transactions) == 0 {
+		return nil, ErrEmptyBatch
+	}
 
+	// The TxID is calculated by the server, a TxID sent by the client must be the same
+	txIDs := make(map[string]int)
+	for i := range transactions {
+		txID, err := transactions[i].ID()
 		if err != nil {
-			return err
+			return nil, &BatchError{Index: i, Err: err}
 		}
 
-		if !verified {
-			return errors.New("At least one of the transactions was invalid")
+		if transactions[i].TxID != "" && transactions[i].TxID != txID {
+			return nil, &BatchError{Index: i, Err: &RuleError{Code: CodeTxIDMismatch, Message: "TxID does not match the transaction"}}
 		}
 
-		if !t.Timestamp.Equal(timestamp) {
-			return errors.New("Timestamp must be same for all transactions")
+		if _, contains := txIDs[txID]; contains {
+			return nil, &BatchError{Index: i, Err: &RuleError{Code: CodeDuplicateTransaction, Message: "Transactions must not be sent twice"}}
 		}
 
-		prevTransactions[t.PrevSignature].Value -= t.Value
+		txIDs[txID] = i
+		transactions[i].TxID = txID
 	}
 
-	// Verify that all previous transactions will now have 0 balance
-	for _, pt := range prevTransactions {
-		if pt.Value != 0 {
-			return errors.New("Total value of all transactions (in and out) must be equal")
+	// An output must not be spent twice, in the batch or in the ledger
+	var outpoints []model.Outpoint
+	var keys []string
+	spentBy := make(map[model.Outpoint]int)
+	for i, t := range transactions {
+		for _, input := range t.Inputs {
+			if _, contains := spentBy[input.Outpoint]; contains {
+				return nil, &BatchError{Index: i, Err: fmt.Errorf("%w: %s", repository.ErrAlreadySpent, input.Outpoint)}
+			}
+
+			spentBy[input.Outpoint] = i
+			outpoints = append(outpoints, input.Outpoint)
+			keys = append(keys, input.Outpoint.String())
 		}
 	}
 
-	// Save transactions
-	for _, t := range transactions {
-		err := repository.SaveTransaction(&t) // TODO: Save all transactions in one call at the end
+	// Only one batch at a time may spend the same output
+	unlock := spendLocks.Lock(keys)
+	defer unlock()
+
+	// Get previous transaction for each input, pending or confirmed
+	lookup, err := lookupOutputs(outpoints)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if len(lookup.Spent) > 0 {
+		return nil, &BatchError{Index: spentBy[lookup.Spent[0]], Err: fmt.Errorf("%w: %s", repository.ErrAlreadySpent, lookup.Spent[0])}
+	}
+
+	// Missing previous transactions are reported by VerifyTransaction
+	for i, t := range transactions {
+		verified, err := VerifyTransaction(&t, lookup.Unspent)
 
 		if err != nil {
-			return err
+			return nil, &BatchError{Index: i, Err: err}
 		}
-	}
 
-	return nil
-}
+		if !verified {
+			return nil, &BatchError{Index: i, Err: errors.New("At least one of the transactions was invalid")}
+		}
+	}
 
-// VerifyTransaction verifies all properties for the transaction.
-func VerifyTransaction(t *model.Transaction, pt *model.Transaction) (bool, error) {
-	tv, _ := VerifySignature(t)
-	ptv, _ := VerifySignature(t)
+	// The fees are sent to the operator in the same batch
+	fee, err := collectFees(transactions, lookup.Unspent)
+	if err != nil {
+		return nil, err
+	}
 
-	// Verify prevTrasaction was found
-	if pt == nil {
-		return false, errors.New("Previous transaction not found or is invalid")
+	fees := make(map[string]int64)
+	for i := range transactions {
+		fees[transactions[i].TxID] = Fee(&transactions[i], lookup.Unspent)
 	}
 
-	// Verify Signature of both Transactions
-	if !tv || !ptv {
-		return false, errors.New("Signatures must be valid for both transactions")
+	batch := transactions
+	if fee != nil {
+		batch = append(transactions[:len(transactions):len(transactions)], *fee)
 	}
 
-	// Verify that new transaction matches prevTransaction signature
-	if t.PrevSignature != pt.Signature {
-		return false, errors.New("Signature must match previous transaction")
+	// The transactions are pending until the mempool confirms them
+	receipts, err := pool.add(batch, fees)
+
+	var commitErr *repository.CommitError
+	if errors.As(err, &commitErr) {
+		if i, contains := txIDs[commitErr.TxID]; contains {
+			return nil, &BatchError{Index: i, Err: commitErr.Err}
+		}
 	}
 
-	// Verify that the owner matches
-	if pt.ToAddress != t.PubKey {
-		return false, errors.New("Owner must match previous transaction")
+	if err != nil {
+		return nil, err
 	}
 
-	// Verify Timestamp of new transaction > prevTransaction
-	if pt.Timestamp.After(t.Timestamp) {
-		return false, errors.New("Timestamp must be after previous transaction")
+	// The receipt of the fee transaction is not returned
+	receipts = receipts[:len(transactions)]
+	for i := range transactions {
+		transactions[i].Acceptance = &model.Acceptance{AcceptedAt: receipts[i].AcceptedAt, Sequence: receipts[i].Sequence}
 	}
 
-	// Verify Timestamp not in the future
-	if t.Timestamp.After(time.Now()) {
-		return false, errors.New("Timestamp must be less than current time")
+	// Mining takes time, so with proof of work a full mempool is confirmed by StartMempool
+	if miner.enabled {
+		if pool.full() {
+			pool.wake()
+		}
+		return receipts, nil
 	}
 
-	// Verify SendTo is a valid Public Key
-	_, err := ecdsa.ParsePubKey(t.ToAddress)
-	if err != nil {
-		return false, errors.New("SendTo must be a valid Public Key")
+	// A full mempool is confirmed now, the transactions are accepted even if it fails
+	for pool.full() {
+		block, err := ConfirmPending()
+		if err != nil {
+			log.Print(err)
+		}
+		if err != nil || block == nil {
+			break
+		}
 	}
 
-	// Verify prevTrasaction > new transaction and subtract from it
-	if t.Value > pt.Value {
-		return false, errors.New("Previous transaction value must be equal to the combined value of new transactions")
+	return receipts, nil
+}
+
+// VerifyTransaction verifies the transaction with the consensus rules. prevTransactions are the transactions
+// of the outputs spent by the inputs. The error of a failed rule is a *RuleError with the code of the rule.
+func VerifyTransaction(t *model.Transaction, prevTransactions map[model.Outpoint]*model.Transaction) (bool, error) {
+	v := &verification{t: t, prevTransactions: prevTransactions}
+
+	for _, rule := range rules {
+		if err := rule.check(v); err != nil {
+			return false, &RuleError{Code: rule.code, Message: err.Error()}
+		}
 	}
 
 	return true, nil
 }
 
-// VerifySignature verifies the signature of a transaction using the transaction hash and public key.
+// legacySignaturesUntil is the end of the legacy signatures, see config.LegacySignaturesUntil.
+var legacySignaturesUntil = config.InitConfig().LegacySignaturesUntil
+
+// VerifySignature verifies the signatures of all the inputs of a saved transaction using the transaction
+// hash and their public key. A transaction converted from a record (see model.Record) is signed with the
+// hash of the record, or with the legacy hash of the record if its timestamp is before
+// config.LegacySignaturesUntil.
 func VerifySignature(transaction *model.Transaction) (bool, error) {
-	key, err := ecdsa.ParsePubKey(transaction.PubKey)
+	return verifySignature(transaction, transaction.Timestamp)
+}
+
+// verifySignature verifies the signatures like VerifySignature, the legacy hash is accepted if the
+// transaction was signed before config.LegacySignaturesUntil. The timestamp of a new transaction is
+// chosen by the client, so it is verified with the current time.
+func verifySignature(transaction *model.Transaction, signedAt time.Time) (bool, error) {
+	hash, err := transaction.Hash()
 	if err != nil {
 		return false, err
 	}
 
-	hash, err := transaction.Hash()
+	result, err := verifyInputs(transaction, hash)
+	if result || err != nil {
+		return result, err
+	}
+
+	record, ok := transaction.Record()
+	if !ok {
+		return false, nil
+	}
+
+	hash, err = record.Hash()
 	if err != nil {
 		return false, err
 	}
 
-	result, err := ecdsa.Verify(key, hash[:], transaction.Signature)
+	result, err = verifyInputs(transaction, hash)
+	if result || err != nil || !signedAt.Before(legacySignaturesUntil) {
+		return result, err
+	}
 
+	hash, err = record.LegacyHash()
 	if err != nil {
 		return false, err
 	}
 
-	return result, nil
+	return verifyInputs(transaction, hash)
+}
+
+// verifyInputs verifies that every input is signed with the hash by its public key.
+func verifyInputs(transaction *model.Transaction, hash []byte) (bool, error) {
+	for _, input := range transaction.Inputs {
+		key, err := ecdsa.ParsePubKey(input.PubKey)
+		if err != nil {
+			return false, err
+		}
+
+		result, err := ecdsa.Verify(key, hash, input.Signature)
+		if !result || err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
 }
 
 // CalculateSignature calculates the signature for a transaction using the hash of the transaction and private key.
@@ -161,71 +296,108 @@ func CalculateSignature(transaction *model.Transaction, privKey string) (string,
 	return signature, nil
 }
 
-// CreateGenesisTransaction creates a new genesis transaction. For testing use only.
+// SignInputs signs all the inputs of the transaction with the private key.
+func SignInputs(transaction *model.Transaction, privKey string) error {
+	signature, err := CalculateSignature(transaction, privKey)
+	if err != nil {
+		return err
+	}
+
+	for i := range transaction.Inputs {
+		transaction.Inputs[i].Signature = signature
+	}
+
+	return nil
+}
+
+// genesisValue is the value sent to the Genesis account by a genesis transaction.
+const genesisValue = 1000000
+
+// CreateGenesisTransaction creates a new genesis transaction and saves it in a block. For testing use only.
 func CreateGenesisTransaction() (*model.Transaction, error) {
 	config := config.InitConfig()
 	t := model.NewTransaction()
 
-	t.Value = 1000000
-	t.PrevSignature = "GENESIS"
-	t.PubKey = config.GenesisPubKey
-	t.ToAddress = config.GenesisPubKey
+	t.Outputs = []model.Output{{ToAddress: config.GenesisPubKey, Value: genesisValue}}
 	t.Timestamp = time.Now()
 
-	signature, err := CalculateSignature(t, config.GenesisPrivKey)
+	txID, err := t.ID()
 	if err != nil {
 		return nil, err
 	}
 
-	t.Signature = signature
+	t.TxID = txID
 
-	err = repository.SaveTransaction(t)
+	pool.mutex.Lock()
+	acceptances, err := pool.accept(1)
+	pool.mutex.Unlock()
 	if err != nil {
 		return nil, err
 	}
+	t.Acceptance = &acceptances[0]
+
+	// The genesis transaction is sealed into its own block, like the batches of the mempool
+	pool.confirm.Lock()
+	_, err = saveBlock([]model.Transaction{*t}, nil)
+	pool.confirm.Unlock()
+	if err != nil {
+		return nil, storageError(err)
+	}
 
 	return t, nil
 }
 
-// TransferFromGenesisAccount transfer a specified amount to a wallet from the Genesis wallet. For testing use only.
-func TransferFromGenesisAccount(signature string, sendTo string, amount int64) (*[]model.Transaction, error) {
+// TransferFromGenesisAccount transfer a specified amount to a wallet from the Genesis wallet, spending
+// the output of the transaction sent to the Genesis wallet. The change pays the minimum fee. For testing use only.
+func TransferFromGenesisAccount(txID string, sendTo string, amount int64) (*model.Transaction, error) {
 	config := config.InitConfig()
 
-	pt, err := repository.GetTransaction(signature, true)
+	pt, err := GetTransaction(txID)
 	if err != nil {
 		return nil, err
 	}
 
-	if pt == nil {
-		return nil, errors.New("Transaction does not exist")
+	index := -1
+	for i, output := range pt.Outputs {
+		if output.ToAddress == config.GenesisPubKey {
+			index = i
+			break
+		}
 	}
 
-	timestamp := time.Now()
+	if index < 0 {
+		return nil, errors.New("Transaction does not have an output to the Genesis wallet")
+	}
 
-	transactions := make([]model.Transaction, 2)
-	transactions[0].Value = amount
-	transactions[0].PubKey = config.GenesisPubKey
-	transactions[0].Timestamp = timestamp
-	transactions[0].PrevSignature = pt.Signature
-	transactions[0].ToAddress = sendTo
-	signature1, err := CalculateSignature(&transactions[0], config.GenesisPrivKey)
+	t := model.NewTransaction()
+	t.Timestamp = time.Now()
+	t.Inputs = []model.Input{{Outpoint: pt.Outpoint(index), PubKey: config.GenesisPubKey}}
+	t.Outputs = []model.Output{{ToAddress: sendTo, Value: amount}, {ToAddress: config.GenesisPubKey}}
+
+	// The change pays the minimum fee
+	size, err := t.Size()
 	if err != nil {
 		return nil, err
 	}
-	transactions[0].Signature = signature1
 
-	transactions[1].Value = pt.Value - amount
-	transactions[1].PubKey = config.GenesisPubKey
-	transactions[1].Timestamp = timestamp
-	transactions[1].PrevSignature = pt.Signature
-	transactions[1].ToAddress = config.GenesisPubKey
-	signature2, err := CalculateSignature(&transactions[1], config.GenesisPrivKey)
+	change := pt.Outputs[index].Value - amount - feePolicy.MinimumFee(size, len(t.Inputs))
+	if change < 0 {
+		return nil, errors.New("Amount and fee must not be greater than the value of the output")
+	}
+
+	if change > 0 {
+		t.Outputs[1].Value = change
+	} else {
+		t.Outputs = t.Outputs[:1]
+	}
+
+	err = SignInputs(t, config.GenesisPrivKey)
 	if err != nil {
 		return nil, err
 	}
-	transactions[1].Signature = signature2
 
+	transactions := []model.Transaction{*t}
 	err = AddTransactions(transactions)
 
-	return &transactions, err
+	return &transactions[0], err
 }
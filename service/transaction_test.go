@@ -1,7 +1,15 @@
 package service
 
 import (
+	"cryptocoin-server/config"
 	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -12,36 +20,40 @@ func TestVerifyTranscation(t *testing.T) {
 
 	pt := new(model.Transaction)
 
-	pt.Value = 100
 	pt.Timestamp = time.Now()
-	pt.PubKey = pw.PubKey
-	pt.ToAddress = nw.PubKey
-	pt.Signature, _ = CalculateSignature(pt, pw.PrivKey)
+	pt.Outputs = []model.Output{{ToAddress: pw.PubKey, Value: 100}}
+	pt.TxID, _ = pt.ID()
 
 	nt := new(model.Transaction)
 
-	nt.PrevSignature = pt.Signature
-	nt.Value = 100
 	nt.Timestamp = time.Now()
-	nt.PubKey = nw.PubKey
-	nt.ToAddress = nw.PubKey // Send to send
-	nt.Signature, _ = CalculateSignature(nt, nw.PrivKey)
+	nt.Inputs = []model.Input{{Outpoint: pt.Outpoint(0), PubKey: pw.PubKey}}
+	nt.Outputs = []model.Output{{ToAddress: nw.PubKey, Value: 60}, {ToAddress: pw.PubKey, Value: 40}}
+	SignInputs(nt, pw.PrivKey)
 
-	result, err := VerifyTransaction(nt, pt)
+	result, err := VerifyTransaction(nt, map[model.Outpoint]*model.Transaction{pt.Outpoint(0): pt})
 
 	if err != nil || result != true {
 		t.Error("VerifyTransaction failed:", nt, pt, err)
 	}
+
+	// The outputs must not send more than the inputs
+	nt.Outputs[1].Value = 50
+	SignInputs(nt, pw.PrivKey)
+
+	if result, err := VerifyTransaction(nt, map[model.Outpoint]*model.Transaction{pt.Outpoint(0): pt}); result || err == nil {
+		t.Error("Unbalanced transaction was verified:", nt)
+	}
 }
 
 func TestCalculateSignature(t *testing.T) {
 	wallet, err := model.NewWallet()
 	transacation := new(model.Transaction)
 
-	transacation.Value = 100
 	transacation.Timestamp = time.Now()
-	transacation.PubKey = wallet.PubKey
-	transacation.Signature, _ = CalculateSignature(transacation, wallet.PrivKey)
+	transacation.Inputs = []model.Input{{PubKey: wallet.PubKey}}
+	transacation.Outputs = []model.Output{{ToAddress: wallet.PubKey, Value: 100}}
+	transacation.Inputs[0].Signature, _ = CalculateSignature(transacation, wallet.PrivKey)
 
 	if err != nil {
 		t.Error("CalculateSignature failed:", transacation, wallet)
@@ -50,16 +62,275 @@ func TestCalculateSignature(t *testing.T) {
 
 func TestVerifySignature(t *testing.T) {
 	wallet, err := model.NewWallet()
+	other, _ := model.NewWallet()
 	transacation := new(model.Transaction)
 
-	transacation.Value = 100
 	transacation.Timestamp = time.Now()
-	transacation.PubKey = wallet.PubKey
-	transacation.Signature, _ = CalculateSignature(transacation, wallet.PrivKey)
+	transacation.Inputs = []model.Input{{PubKey: wallet.PubKey}, {PubKey: wallet.PubKey, Outpoint: model.Outpoint{Index: 1}}}
+	transacation.Outputs = []model.Output{{ToAddress: wallet.PubKey, Value: 100}}
+	SignInputs(transacation, wallet.PrivKey)
 
 	result, err := VerifySignature(transacation)
 
 	if err != nil || result == false {
 		t.Error("TestVerifySignature failed:", "transaction=", transacation, "wallet=", wallet)
 	}
+
+	// Every input must be signed by its public key
+	transacation.Inputs[1].PubKey = other.PubKey
+
+	if result, _ := VerifySignature(transacation); result {
+		t.Error("Input signed by another key was verified:", transacation)
+	}
+}
+
+func TestAddTransactions(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, err := CreateGenesisTransaction()
+	if err != nil {
+		t.Fatal("CreateGenesisTransaction failed:", err)
+	}
+
+	transaction, err := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	if err != nil {
+		t.Fatal("TransferFromGenesisAccount failed:", transaction, err)
+	}
+
+	if lookup, _ := repo.LookupOutputs([]model.Outpoint{genesis.Outpoint(0)}); len(lookup.Spent) != 1 {
+		t.Error("Genesis transaction should be used:", lookup)
+	}
+
+	received, err := repo.GetTransaction(transaction.TxID)
+	if received == nil || received.Outputs[0].Value != 100 || received.Outputs[0].ToAddress != wallet.PubKey || received.Outputs[1].Value != 1000000-100 || err != nil {
+		t.Error("Transaction not saved:", received, err)
+	}
+}
+
+func TestAddTransactionsTxID(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+
+	nt := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesis.Outputs[0].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: genesis.Outputs[0].Value}},
+	}
+	SignInputs(&nt, config.InitConfig().GenesisPrivKey)
+	nt.TxID = genesis.TxID
+
+	if err := AddTransactions([]model.Transaction{nt}); err == nil {
+		t.Error("Wrong TxID was accepted")
+	}
+
+	// Signing again does not change the TxID
+	txID, _ := nt.ID()
+	SignInputs(&nt, config.InitConfig().GenesisPrivKey)
+	nt.TxID = ""
+	transactions := []model.Transaction{nt}
+
+	if err := AddTransactions(transactions); transactions[0].TxID != txID || err != nil {
+		t.Error("TxID does not match:", transactions[0].TxID, txID, err)
+	}
+}
+
+func TestAddTransactionsDoubleSpend(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+
+	// The genesis transaction is already used by the first transfer
+	_, err := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+
+	if !errors.Is(err, repository.ErrAlreadySpent) {
+		t.Error("Double spend was accepted:", err)
+	}
+}
+
+func TestAddTransactionsUnbalanced(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+
+	nt := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesis.Outputs[0].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: genesis.Outputs[0].Value + 1}},
+	}
+	SignInputs(&nt, config.InitConfig().GenesisPrivKey)
+
+	err := AddTransactions([]model.Transaction{nt})
+
+	if err == nil {
+		t.Error("Unbalanced transactions were accepted")
+	}
+
+	if lookup, _ := repo.LookupOutputs([]model.Outpoint{genesis.Outpoint(0)}); len(lookup.Unspent) != 1 {
+		t.Error("Genesis transaction should not be used")
+	}
+}
+
+func TestAddTransactionsSpendTwiceInBatch(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+
+	nt := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesis.Outputs[0].ToAddress}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: genesis.Outputs[0].Value}},
+	}
+	SignInputs(&nt, config.InitConfig().GenesisPrivKey)
+	other := nt
+	other.Inputs = append([]model.Input(nil), nt.Inputs...)
+	other.Outputs = []model.Output{{ToAddress: genesis.Outputs[0].ToAddress, Value: genesis.Outputs[0].Value}}
+	SignInputs(&other, config.InitConfig().GenesisPrivKey)
+
+	if err := AddTransactions([]model.Transaction{nt, other}); !errors.Is(err, repository.ErrAlreadySpent) {
+		t.Error("Output spent twice in a batch was accepted:", err)
+	}
+}
+
+func TestAddTransactionsConcurrentDoubleSpend(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	genesis, _ := CreateGenesisTransaction()
+
+	var wg sync.WaitGroup
+	var succeeded int32
+
+	for i := 0; i < 20; i++ {
+		wallet, _ := model.NewWallet()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Error("Expected exactly one spend to succeed:", succeeded)
+	}
+}
+
+func TestGetTransactions(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+
+	transactions, next, err := GetTransactions(repository.Filter{Limit: 2})
+
+	if len(transactions) != 2 || transactions[0].TxID != genesis.TxID || next == "" || err != nil {
+		t.Fatal("First page does not match:", transactions, next, err)
+	}
+
+	cursor, _ := repository.ParseCursor(next)
+	transactions, next, err = GetTransactions(repository.Filter{After: cursor, Limit: 2})
+
+	if len(transactions) != 1 || next != "" || err != nil {
+		t.Error("Last page does not match:", transactions, next, err)
+	}
+}
+
+func TestVerifySignatureVectors(t *testing.T) {
+	data, _ := ioutil.ReadFile("../model/testdata/canonical_vectors.json")
+
+	var vectors struct {
+		Vectors []struct {
+			Name        string
+			Transaction model.Transaction
+		}
+	}
+	json.Unmarshal(data, &vectors)
+
+	verified := 0
+	for _, v := range vectors.Vectors {
+		if len(v.Transaction.Inputs) == 0 || v.Transaction.Inputs[0].Signature == "" {
+			continue
+		}
+
+		result, err := VerifySignature(&v.Transaction)
+		if !result || err != nil {
+			t.Error("Test vector signature is invalid:", v.Name, err)
+		}
+		verified++
+	}
+
+	if verified == 0 {
+		t.Error("No test vector signatures verified")
+	}
+}
+
+func TestVerifySignatureRecord(t *testing.T) {
+	wallet, _ := model.NewWallet()
+
+	record := model.Record{Value: 100, Timestamp: time.Now(), PubKey: wallet.PubKey, ToAddress: wallet.PubKey, PrevTxID: "a"}
+	hash, _ := record.Hash()
+	key, _ := ecdsa.ParsePrivKey(wallet.PrivKey)
+	record.Signature, _ = ecdsa.Sign(key, hash)
+	transaction := record.Transaction()
+
+	if result, err := VerifySignature(&transaction); !result || err != nil {
+		t.Error("Record signature does not verify:", transaction, err)
+	}
+}
+
+func TestVerifySignatureLegacy(t *testing.T) {
+	wallet, _ := model.NewWallet()
+	key, _ := ecdsa.ParsePrivKey(wallet.PrivKey)
+	until := legacySignaturesUntil
+
+	for _, test := range []struct {
+		timestamp time.Time
+		valid     bool
+	}{
+		{until.Add(-time.Hour), true},
+		{until.Add(time.Hour), false},
+	} {
+		record := model.Record{Value: 100, Timestamp: test.timestamp, PubKey: wallet.PubKey, PrevTxID: "a"}
+		hash, _ := record.LegacyHash()
+		record.Signature, _ = ecdsa.Sign(key, hash)
+		transaction := record.Transaction()
+
+		if result, _ := VerifySignature(&transaction); result != test.valid {
+			t.Error("Legacy signature verification does not match:", test.timestamp, result)
+		}
+	}
+}
+
+func TestCheckSignatureLegacyEnded(t *testing.T) {
+	defer func(until time.Time) { legacySignaturesUntil = until }(legacySignaturesUntil)
+	legacySignaturesUntil = time.Now().Add(-time.Hour)
+
+	wallet, _ := model.NewWallet()
+	key, _ := ecdsa.ParsePrivKey(wallet.PrivKey)
+
+	// A new transaction signed with the legacy hash, with a timestamp before the end of the legacy signatures
+	record := model.Record{Value: 100, Timestamp: legacySignaturesUntil.Add(-time.Hour), PubKey: wallet.PubKey, PrevTxID: "a"}
+	hash, _ := record.LegacyHash()
+	record.Signature, _ = ecdsa.Sign(key, hash)
+	transaction := record.Transaction()
+
+	if err := checkSignature(&verification{t: &transaction}); err == nil {
+		t.Error("Legacy signature accepted after the end of the legacy signatures")
+	}
+
+	if result, _ := VerifySignature(&transaction); !result {
+		t.Error("Legacy signature of a saved transaction does not verify")
+	}
 }
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"fmt"
+)
+
+// ValidateTransactions checks the transactions like AddTransactions without saving them, and returns
+// the result of every rule for each transaction with the fee and the change of the batch. Before the
+// consensus rules, the report of a transaction has the checks of the batch: the TxID (txid_mismatch),
+// transactions sent twice (duplicate_transaction) and outputs already spent in the batch, by a pending
+// transaction or in the ledger (already_spent).
+func ValidateTransactions(transactions []model.Transaction) (*model.ValidationReport, error) {
+	if len(transactions) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	report := new(model.ValidationReport)
+	report.Valid = true
+	report.Transactions = make([]model.TransactionReport, len(transactions))
+
+	var outpoints []model.Outpoint
+	for _, t := range transactions {
+		for _, input := range t.Inputs {
+			outpoints = append(outpoints, input.Outpoint)
+		}
+	}
+
+	lookup, err := lookupOutputs(outpoints)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	ledgerSpent := make(map[model.Outpoint]bool)
+	for _, outpoint := range lookup.Spent {
+		ledgerSpent[outpoint] = true
+	}
+
+	txIDs := make(map[string]bool)
+	batchSpent := make(map[model.Outpoint]bool)
+
+	for i := range transactions {
+		t := &transactions[i]
+		tr := &report.Transactions[i]
+		tr.Index = i
+
+		// The TxID is calculated by the server, a TxID sent by the client must be the same
+		idResult := model.RuleResult{Code: CodeTxIDMismatch, Status: model.RulePassed}
+		txID, err := t.ID()
+		switch {
+		case err != nil:
+			idResult.Status, idResult.Message = model.RuleFailed, err.Error()
+		case t.TxID != "" && t.TxID != txID:
+			idResult.Status, idResult.Message = model.RuleFailed, "TxID does not match the transaction"
+		default:
+			t.TxID = txID
+		}
+		tr.TxID = t.TxID
+
+		duplicateResult := model.RuleResult{Code: CodeDuplicateTransaction, Status: model.RulePassed}
+		if idResult.Status != model.RulePassed {
+			duplicateResult.Status = model.RuleSkipped
+		} else if txIDs[txID] {
+			duplicateResult.Status, duplicateResult.Message = model.RuleFailed, "Transactions must not be sent twice"
+		}
+		txIDs[txID] = true
+
+		// An output must not be spent twice, in the batch or in the ledger
+		spentResult := model.RuleResult{Code: CodeAlreadySpent, Status: model.RulePassed}
+		for _, input := range t.Inputs {
+			if (batchSpent[input.Outpoint] || ledgerSpent[input.Outpoint]) && spentResult.Status == model.RulePassed {
+				spentResult.Status, spentResult.Message = model.RuleFailed, fmt.Sprintf("%v: %s", repository.ErrAlreadySpent, input.Outpoint)
+			}
+			batchSpent[input.Outpoint] = true
+		}
+
+		tr.Rules = append([]model.RuleResult{idResult, duplicateResult, spentResult}, CheckRules(t, lookup.Unspent)...)
+
+		tr.Valid = true
+		for _, result := range tr.Rules {
+			if result.Status != model.RulePassed {
+				tr.Valid = false
+			}
+		}
+
+		// Fee and change are only known if the values of the inputs could be summed
+		if ruleStatus(tr.Rules, CodeValueOverflow) == model.RulePassed {
+			tr.Fee = Fee(t, lookup.Unspent)
+			tr.Change = change(t)
+			report.Fee += tr.Fee
+			report.Change += tr.Change
+		}
+
+		report.Valid = report.Valid && tr.Valid
+	}
+
+	return report, nil
+}
+
+// ruleStatus returns the status of the rule in the results.
+func ruleStatus(results []model.RuleResult, code string) string {
+	for _, result := range results {
+		if result.Code == code {
+			return result.Status
+		}
+	}
+	return ""
+}
+
+// change returns the total value of the outputs sent back to the owners of the inputs.
+func change(t *model.Transaction) int64 {
+	var value int64
+	for _, output := range t.Outputs {
+		if t.HasInputFrom(output.ToAddress) {
+			value += output.Value
+		}
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"testing"
+	"time"
+)
+
+func TestValidateTransactions(t *testing.T) {
+	defer setFeePolicy(0, 10)()
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+	genesisKey := config.InitConfig().GenesisPubKey
+
+	genesis, _ := CreateGenesisTransaction()
+
+	nt := model.Transaction{
+		Timestamp: time.Now(),
+		Inputs:    []model.Input{{Outpoint: genesis.Outpoint(0), PubKey: genesisKey}},
+		Outputs:   []model.Output{{ToAddress: wallet.PubKey, Value: 100}, {ToAddress: genesisKey, Value: 1000000 - 100 - 10}},
+	}
+	SignInputs(&nt, config.InitConfig().GenesisPrivKey)
+
+	report, err := ValidateTransactions([]model.Transaction{nt})
+
+	if report == nil || !report.Valid || report.Fee != 10 || report.Change != 1000000-100-10 || err != nil {
+		t.Fatal("Valid batch does not match:", report, err)
+	}
+
+	if tr := report.Transactions[0]; tr.TxID == "" || len(tr.Rules) != len(rules)+3 || tr.Fee != 10 {
+		t.Error("Transaction report does not match:", tr)
+	}
+
+	// Nothing is saved
+	if lookup, _ := repo.LookupOutputs([]model.Outpoint{genesis.Outpoint(0)}); len(lookup.Unspent) != 1 {
+		t.Error("Validated transaction was saved:", lookup)
+	}
+
+	// The second transaction spends the same output and is not signed
+	unsigned := nt
+	unsigned.Outputs = []model.Output{{ToAddress: wallet.PubKey, Value: 1000001}}
+
+	report, err = ValidateTransactions([]model.Transaction{nt, unsigned})
+
+	if report == nil || report.Valid || !report.Transactions[0].Valid || report.Transactions[1].Valid || err != nil {
+		t.Fatal("Invalid batch does not match:", report, err)
+	}
+
+	statuses := map[string]string{
+		CodeAlreadySpent:            model.RuleFailed,
+		CodeInvalidSignature:        model.RuleFailed,
+		CodeOutputsExceedInputs:     model.RuleFailed,
+		CodeFeeTooLow:               model.RuleSkipped,
+		CodeTimestampBeforePrevious: model.RulePassed,
+	}
+
+	for code, status := range statuses {
+		if result := ruleStatus(report.Transactions[1].Rules, code); result != status {
+			t.Error("Rule status does not match:", code, result)
+		}
+	}
+
+	if report.Transactions[1].Fee != -1 {
+		t.Error("Fee does not match:", report.Transactions[1].Fee)
+	}
+}
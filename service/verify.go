@@ -0,0 +1,280 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"fmt"
+)
+
+// ledgerVerification is the state of VerifyLedger: the transactions of the ledger, and the transactions
+// spending each output in the order of the ledger.
+type ledgerVerification struct {
+	transactions map[string]*model.Transaction
+	ordered      []*model.Transaction
+	spenders     map[model.Outpoint][]*model.Transaction
+	report       *model.LedgerReport
+}
+
+// VerifyLedger checks every transaction saved in the ledger, in the order of timestamp and TxID from the
+// genesis transactions: the signatures verify, the inputs spend existing outputs, an output is spent at
+// most once, and the value is conserved by each batch. A batch is the transactions whose fees are
+// collected by a fee transaction, with the fee transaction; a transaction without fee is a batch alone.
+// A transaction without inputs must be a fee transaction or a genesis transaction sending the
+// configured allocation to the Genesis account, and the total value of the unspent outputs must be the
+// genesis allocation. A transaction the repository stored in seconds (see model.Transaction) whose
+// signature does not verify is reported apart, it is not a violation. Sibling records (see
+// model.Record) all spend output 0 of the previous record, together they spend its whole value. If
+// quarantine is true, the transactions breaking a rule are quarantined (see repository.Repository).
+func VerifyLedger(quarantine bool) (*model.LedgerReport, error) {
+	v := &ledgerVerification{
+		transactions: make(map[string]*model.Transaction),
+		spenders:     make(map[model.Outpoint][]*model.Transaction),
+		report:       &model.LedgerReport{Violations: []model.Violation{}},
+	}
+
+	if err := v.load(); err != nil {
+		return nil, storageError(err)
+	}
+
+	v.checkInputs()
+	v.checkSpends()
+	v.checkConservation()
+	v.checkSupply()
+
+	if quarantine {
+		txIDs := v.violatingTxIDs()
+		if len(txIDs) > 0 {
+			if err := repo.Quarantine(txIDs); err != nil {
+				return nil, storageError(err)
+			}
+			v.report.Quarantined = txIDs
+		}
+	}
+
+	return v.report, nil
+}
+
+// load reads all the transactions of the ledger.
+func (v *ledgerVerification) load() error {
+	filter := repository.Filter{Limit: 100}
+
+	for {
+		transactions, err := repo.GetTransactions(filter)
+		if err != nil {
+			return err
+		}
+
+		for i := range transactions {
+			t := &transactions[i]
+			v.transactions[t.TxID] = t
+			v.ordered = append(v.ordered, t)
+		}
+
+		if len(transactions) < filter.Limit {
+			v.report.Transactions = len(v.ordered)
+			return nil
+		}
+		filter.After = repository.NewCursor(&transactions[len(transactions)-1])
+	}
+}
+
+// violation adds a violation to the report.
+func (v *ledgerVerification) violation(txID string, code string, format string, args ...interface{}) {
+	v.report.Violations = append(v.report.Violations, model.Violation{TxID: txID, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkInputs verifies the signatures and that the inputs spend existing outputs.
+func (v *ledgerVerification) checkInputs() {
+	for _, t := range v.ordered {
+		if len(t.Inputs) == 0 {
+			continue
+		}
+
+		if verified, err := VerifySignature(t); err != nil {
+			v.violation(t.TxID, model.ViolationInvalidSignature, "Signature does not verify: %v", err)
+		} else if !verified && t.TimestampInSeconds {
+			v.report.LostPrecision = append(v.report.LostPrecision, t.TxID)
+		} else if !verified {
+			v.violation(t.TxID, model.ViolationInvalidSignature, "Signature does not verify")
+		}
+
+		for _, input := range t.Inputs {
+			if v.output(input.Outpoint) == nil {
+				v.violation(t.TxID, model.ViolationMissingInput, "Output %s does not exist", input.Outpoint)
+				continue
+			}
+			v.spenders[input.Outpoint] = append(v.spenders[input.Outpoint], t)
+		}
+	}
+}
+
+// checkSpends verifies that every output is spent at most once, by the first transaction spending it.
+func (v *ledgerVerification) checkSpends() {
+	for _, t := range v.ordered {
+		for _, input := range t.Inputs {
+			spenders := v.spenders[input.Outpoint]
+			if len(spenders) < 2 || spenders[0] == t || siblingRecords(spenders) {
+				continue
+			}
+			v.violation(t.TxID, model.ViolationDoubleSpend, "Output %s is already spent by %s", input.Outpoint, spenders[0].TxID)
+		}
+	}
+}
+
+// checkConservation verifies that the outputs of a transaction do not exceed its inputs, and that the
+// fees are collected by a fee transaction of the same value.
+func (v *ledgerVerification) checkConservation() {
+	collectedBy := make(map[string]string)
+	for _, t := range v.ordered {
+		for _, txID := range t.Collects {
+			if feeTxID, contains := collectedBy[txID]; contains {
+				v.violation(t.TxID, model.ViolationValueNotConserved, "Fee of %s is already collected by %s", txID, feeTxID)
+				continue
+			}
+			collectedBy[txID] = t.TxID
+		}
+	}
+
+	fees := make(map[string]int64)
+	for _, t := range v.ordered {
+		if len(t.Inputs) == 0 {
+			continue
+		}
+
+		// Sibling records are checked together, by the first one
+		spenders := v.spenders[t.Inputs[0].Outpoint]
+		if len(spenders) > 1 && siblingRecords(spenders) {
+			if spenders[0] == t {
+				v.checkSiblings(spenders)
+			}
+			continue
+		}
+
+		inputValue, complete := v.inputValue(t)
+		if !complete {
+			continue
+		}
+
+		fee := inputValue - t.OutputValue()
+		feeTxID, collected := collectedBy[t.TxID]
+
+		switch {
+		case fee < 0:
+			v.violation(t.TxID, model.ViolationValueNotConserved, "Outputs exceed the inputs by %d", -fee)
+		case fee > 0 && !collected:
+			v.violation(t.TxID, model.ViolationValueNotConserved, "Fee of %d is not collected", fee)
+		case collected:
+			fees[feeTxID] += fee
+		}
+	}
+
+	for _, t := range v.ordered {
+		if !t.IsFee() {
+			continue
+		}
+
+		for _, txID := range t.Collects {
+			if _, contains := v.transactions[txID]; !contains {
+				v.violation(t.TxID, model.ViolationValueNotConserved, "Collected transaction %s does not exist", txID)
+			}
+		}
+
+		if t.OutputValue() != fees[t.TxID] {
+			v.violation(t.TxID, model.ViolationValueNotConserved, "Fee transaction sends %d, the collected fees are %d", t.OutputValue(), fees[t.TxID])
+		}
+	}
+}
+
+// checkSiblings verifies that the sibling records send the whole value of the record they spend.
+func (v *ledgerVerification) checkSiblings(siblings []*model.Transaction) {
+	var outputValue int64
+	for _, t := range siblings {
+		outputValue += t.OutputValue()
+	}
+
+	outpoint := siblings[0].Inputs[0].Outpoint
+	if inputValue := v.output(outpoint).Value; outputValue != inputValue {
+		v.violation(siblings[0].TxID, model.ViolationValueNotConserved, "Sibling records send %d of the %d of %s", outputValue, inputValue, outpoint)
+	}
+}
+
+// checkSupply verifies that the transactions without inputs are fee or genesis transactions, and that
+// the unspent outputs have the value allocated by the genesis transactions.
+func (v *ledgerVerification) checkSupply() {
+	for _, t := range v.ordered {
+		switch {
+		case len(t.Inputs) > 0 || t.IsFee():
+		case genesisTransaction(t):
+			v.report.GenesisAllocation += t.OutputValue()
+		default:
+			v.violation(t.TxID, model.ViolationInvalidGenesis, "Transaction without inputs sends %d, it is not a genesis transaction", t.OutputValue())
+		}
+
+		for i, output := range t.Outputs {
+			if _, spent := v.spenders[t.Outpoint(i)]; !spent {
+				v.report.Supply += output.Value
+			}
+		}
+	}
+
+	if v.report.Supply != v.report.GenesisAllocation {
+		v.violation("", model.ViolationSupplyMismatch, "Supply is %d, the genesis allocation is %d", v.report.Supply, v.report.GenesisAllocation)
+	}
+}
+
+// output returns the output of the ledger, or nil if it does not exist.
+func (v *ledgerVerification) output(outpoint model.Outpoint) *model.Output {
+	t, contains := v.transactions[outpoint.TxID]
+	if !contains {
+		return nil
+	}
+	return t.Output(outpoint)
+}
+
+// inputValue returns the total value of the outputs spent by the transaction, and false if an output
+// does not exist.
+func (v *ledgerVerification) inputValue(t *model.Transaction) (int64, bool) {
+	var value int64
+	for _, input := range t.Inputs {
+		output := v.output(input.Outpoint)
+		if output == nil {
+			return 0, false
+		}
+		value += output.Value
+	}
+	return value, true
+}
+
+// violatingTxIDs returns the TxIDs of the transactions with a violation, once each.
+func (v *ledgerVerification) violatingTxIDs() []string {
+	var txIDs []string
+	seen := make(map[string]bool)
+
+	for _, violation := range v.report.Violations {
+		if violation.TxID != "" && !seen[violation.TxID] {
+			seen[violation.TxID] = true
+			txIDs = append(txIDs, violation.TxID)
+		}
+	}
+
+	return txIDs
+}
+
+// genesisTransaction returns true if the transaction sends the genesis allocation to the Genesis
+// account, as created by CreateGenesisTransaction, without inputs or collected fees.
+func genesisTransaction(t *model.Transaction) bool {
+	return len(t.Inputs) == 0 && len(t.Collects) == 0 && len(t.Outputs) == 1 &&
+		t.Outputs[0].ToAddress == config.InitConfig().GenesisPubKey && t.Outputs[0].Value == genesisValue
+}
+
+// siblingRecords returns true if the transactions are sibling records: converted records with the same
+// timestamp, spending output 0 of the same record.
+func siblingRecords(transactions []*model.Transaction) bool {
+	for _, t := range transactions {
+		if _, ok := t.Record(); !ok || !t.Timestamp.Equal(transactions[0].Timestamp) || t.Inputs[0].Outpoint != transactions[0].Inputs[0].Outpoint {
+			return false
+		}
+	}
+	return true
+}
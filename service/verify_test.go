@@ -0,0 +1,148 @@
+package service
+
+import (
+	"cryptocoin-server/config"
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"sort"
+	"testing"
+	"time"
+)
+
+// ledgerRepository returns the transactions of a ledger as they are stored, even if the other
+// repositories would not save them, and keeps the quarantined TxIDs.
+type ledgerRepository struct {
+	repository.Repository
+	transactions []model.Transaction
+	quarantined  []string
+}
+
+func (r *ledgerRepository) GetTransactions(filter repository.Filter) ([]model.Transaction, error) {
+	return r.transactions, nil
+}
+
+func (r *ledgerRepository) Quarantine(txIDs []string) error {
+	r.quarantined = append(r.quarantined, txIDs...)
+	return nil
+}
+
+// signedRecord returns the transaction converted from a record signed by the wallet.
+func signedRecord(txID string, timestamp time.Time, prevTxID string, value int64, wallet *model.Wallet) model.Transaction {
+	record := model.Record{TxID: txID, Timestamp: timestamp, ToAddress: wallet.PubKey, Value: value, PubKey: wallet.PubKey, PrevTxID: prevTxID}
+	hash, _ := record.Hash()
+	key, _ := ecdsa.ParsePrivKey(wallet.PrivKey)
+	record.Signature, _ = ecdsa.Sign(key, hash)
+	return record.Transaction()
+}
+
+func TestVerifyLedger(t *testing.T) {
+	defer setFeePolicy(1, 0)()
+	InitService(repository.NewMemoryRepository())
+	pool = newMempool(10)
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	TransferFromGenesisAccount(first.TxID, wallet.PubKey, 100)
+	ConfirmPending()
+
+	report, err := VerifyLedger(false)
+	if err != nil {
+		t.Fatal("VerifyLedger failed:", err)
+	}
+
+	// The genesis transaction, and the transfers with the fee transaction of each
+	if report.Transactions != 5 || len(report.Violations) != 0 || report.Supply != 1000000 || report.GenesisAllocation != 1000000 {
+		t.Error("Report of a valid ledger does not match:", report)
+	}
+}
+
+func TestVerifyLedgerViolations(t *testing.T) {
+	genesisKey := config.InitConfig().GenesisPrivKey
+	wallet, _ := model.NewWallet()
+	now := time.Now().UTC()
+
+	transaction := func(txID string, second int, outpoints []model.Outpoint, values ...int64) model.Transaction {
+		t := model.Transaction{TxID: txID, Timestamp: now.Add(time.Duration(second) * time.Second)}
+		for _, outpoint := range outpoints {
+			t.Inputs = append(t.Inputs, model.Input{Outpoint: outpoint, PubKey: config.InitConfig().GenesisPubKey})
+		}
+		for _, value := range values {
+			t.Outputs = append(t.Outputs, model.Output{ToAddress: wallet.PubKey, Value: value})
+		}
+		SignInputs(&t, genesisKey)
+		return t
+	}
+
+	genesis := model.Transaction{TxID: "g", Timestamp: now, Outputs: []model.Output{{ToAddress: config.InitConfig().GenesisPubKey, Value: 1000000}}}
+	paid := transaction("a", 1, []model.Outpoint{{TxID: "g"}}, 100, 999890)
+	fee := model.Transaction{TxID: "f", Timestamp: paid.Timestamp, Outputs: []model.Output{{ToAddress: wallet.PubKey, Value: 10}}, Collects: []string{"a"}}
+	doubleSpend := transaction("b", 2, []model.Outpoint{{TxID: "g"}}, 1000000)
+	tampered := transaction("c", 3, []model.Outpoint{{TxID: "a"}}, 100)
+	tampered.Outputs[0].Value = 150
+	missing := transaction("d", 4, []model.Outpoint{{TxID: "x"}}, 5)
+	issued := model.Transaction{TxID: "e", Timestamp: now.Add(6 * time.Second), Outputs: []model.Output{{ToAddress: wallet.PubKey, Value: 7}}}
+
+	// Sibling records spend the whole value of the previous record together, the genesis record does
+	// not send the allocation to the Genesis account
+	siblings := now.Add(5 * time.Second)
+	records := []model.Transaction{
+		signedRecord("r0", now, "GENESIS", 50, wallet),
+		signedRecord("r1", siblings, "r0", 20, wallet),
+		signedRecord("r2", siblings, "r0", 30, wallet),
+		signedRecord("r3", time.Date(2019, 1, 1, 0, 0, 0, 5, time.UTC), "r1", 20, wallet),
+		signedRecord("r4", time.Date(2019, 1, 1, 0, 0, 1, 5, time.UTC), "r2", 30, wallet),
+	}
+
+	// The timestamp of r3 was stored in seconds, its signature does not verify. r4 has a timestamp in
+	// whole seconds too, but it was not stored in seconds, so it was tampered with.
+	records[3].Timestamp = records[3].Timestamp.Truncate(time.Second)
+	records[3].TimestampInSeconds = true
+	records[4].Timestamp = records[4].Timestamp.Truncate(time.Second)
+
+	r := &ledgerRepository{Repository: repository.NewMemoryRepository(), transactions: append([]model.Transaction{genesis, records[0], paid, fee, doubleSpend, tampered, missing, issued}, records[1:]...)}
+	InitService(r)
+
+	report, err := VerifyLedger(true)
+	if err != nil {
+		t.Fatal("VerifyLedger failed:", err)
+	}
+
+	var violations []string
+	for _, violation := range report.Violations {
+		violations = append(violations, violation.TxID+" "+violation.Code)
+	}
+	sort.Strings(violations)
+
+	expected := []string{" supply_mismatch", "b double_spend", "c invalid_signature", "c value_not_conserved", "d missing_input", "e invalid_genesis", "r0 invalid_genesis", "r4 invalid_signature"}
+	if len(violations) != len(expected) {
+		t.Fatal("Violations do not match:", violations)
+	}
+	for i := range expected {
+		if violations[i] != expected[i] {
+			t.Error("Violations do not match:", violations)
+			break
+		}
+	}
+
+	if report.Transactions != 12 || report.GenesisAllocation != 1000000 || report.Supply != 2000112 {
+		t.Error("Report does not match:", report)
+	}
+
+	if len(report.LostPrecision) != 1 || report.LostPrecision[0] != "r3" {
+		t.Error("Records with a lost precision do not match:", report.LostPrecision)
+	}
+
+	sort.Strings(r.quarantined)
+	quarantined := []string{"b", "c", "d", "e", "r0", "r4"}
+	if len(r.quarantined) != len(quarantined) || len(report.Quarantined) != len(quarantined) {
+		t.Fatal("Quarantined transactions do not match:", r.quarantined, report.Quarantined)
+	}
+	for i := range quarantined {
+		if r.quarantined[i] != quarantined[i] {
+			t.Error("Quarantined transactions do not match:", r.quarantined)
+			break
+		}
+	}
+}
@@ -2,10 +2,89 @@ package service
 
 import (
 	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"cryptocoin-server/util/ecdsa"
+	"errors"
 )
 
-// GetWallet returns wallet
+// GetWallet returns the wallet summary computed from the ledger: the balance, the unspent
+// outputs sent to the public key, and the time of the first and last activity. The pending balance
+// includes the pending transactions of the mempool.
 func GetWallet(pubKey string) (*model.Wallet, error) {
-	wallet := new(model.Wallet) //TODO: Get wallet summary
+	if _, err := ecdsa.ParsePubKey(pubKey); err != nil || pubKey == "" {
+		return nil, errors.New("Wallet must be a valid Public Key")
+	}
+
+	wallet := new(model.Wallet)
+	wallet.PubKey = pubKey
+	wallet.UnspentOutputs = []model.UnspentOutput{}
+
+	// Get all the transactions sending to the wallet with unspent outputs, one page at a time
+	unspent := false
+	filter := repository.Filter{ToAddress: pubKey, Spent: &unspent, Limit: 100}
+
+	for {
+		transactions, err := repo.GetTransactions(filter)
+		if err != nil {
+			return nil, storageError(err)
+		}
+
+		var outpoints []model.Outpoint
+		for _, t := range transactions {
+			for i, output := range t.Outputs {
+				if output.ToAddress == pubKey {
+					outpoints = append(outpoints, t.Outpoint(i))
+				}
+			}
+		}
+
+		lookup, err := repo.LookupOutputs(outpoints)
+		if err != nil {
+			return nil, storageError(err)
+		}
+
+		for _, outpoint := range outpoints {
+			if t, contains := lookup.Unspent[outpoint]; contains {
+				output := t.Output(outpoint)
+				wallet.Balanance += output.Value
+				wallet.UnspentOutputs = append(wallet.UnspentOutputs, model.UnspentOutput{Outpoint: outpoint, Output: *output})
+			}
+		}
+
+		if len(transactions) < filter.Limit {
+			break
+		}
+		filter.After = repository.NewCursor(&transactions[len(transactions)-1])
+	}
+
+	wallet.UnspentCount = len(wallet.UnspentOutputs)
+	wallet.PendingBalance, wallet.PendingCount = pool.pendingBalance(pubKey, wallet.UnspentOutputs)
+
+	// The first and last transactions sending to or spending from the wallet
+	for _, filter := range []repository.Filter{{ToAddress: pubKey}, {PubKey: pubKey}} {
+		filter.Limit = 1
+
+		for _, descending := range []bool{false, true} {
+			filter.Descending = descending
+
+			transactions, err := repo.GetTransactions(filter)
+			if err != nil {
+				return nil, storageError(err)
+			}
+
+			if len(transactions) == 0 {
+				continue
+			}
+
+			timestamp := transactions[0].Timestamp
+			if !descending && (wallet.FirstActivity == nil || timestamp.Before(*wallet.FirstActivity)) {
+				wallet.FirstActivity = &timestamp
+			}
+			if descending && (wallet.LastActivity == nil || timestamp.After(*wallet.LastActivity)) {
+				wallet.LastActivity = &timestamp
+			}
+		}
+	}
+
 	return wallet, nil
 }
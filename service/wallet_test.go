@@ -1,6 +1,10 @@
 package service
 
-import "testing"
+import (
+	"cryptocoin-server/model"
+	"cryptocoin-server/repository"
+	"testing"
+)
 
 func TestGetWallet(t *testing.T) {
 	w, err := GetWallet("J2nHLtdwZFmxbAe3oniv40NOrekJ1B/tRxu1J2xDJ+n7vGvYoqm4EJLoJUSC9pnTSNHh3dMKBpumEkfynd1huA==")
@@ -9,3 +13,43 @@ func TestGetWallet(t *testing.T) {
 		t.Error("GetWallet failed:", w, err)
 	}
 }
+
+func TestGetWalletBalance(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	genesis, _ := CreateGenesisTransaction()
+	first, _ := TransferFromGenesisAccount(genesis.TxID, wallet.PubKey, 100)
+	second, _ := TransferFromGenesisAccount(first.TxID, wallet.PubKey, 50)
+
+	w, err := GetWallet(wallet.PubKey)
+
+	if w == nil || w.Balanance != 150 || w.UnspentCount != 2 || len(w.UnspentOutputs) != 2 || err != nil {
+		t.Fatal("Wallet balance does not match:", w, err)
+	}
+
+	if !w.FirstActivity.Equal(first.Timestamp) || !w.LastActivity.Equal(second.Timestamp) {
+		t.Error("Wallet activity does not match:", w.FirstActivity, w.LastActivity)
+	}
+
+	g, _ := GetWallet(genesis.Outputs[0].ToAddress)
+
+	if g.Balanance != 1000000-150 || g.UnspentCount != 1 || !g.FirstActivity.Equal(genesis.Timestamp) || !g.LastActivity.Equal(second.Timestamp) {
+		t.Error("Genesis wallet does not match:", g)
+	}
+}
+
+func TestGetWalletEmpty(t *testing.T) {
+	InitService(repository.NewMemoryRepository())
+	wallet, _ := model.NewWallet()
+
+	w, err := GetWallet(wallet.PubKey)
+
+	if w == nil || w.Balanance != 0 || w.UnspentCount != 0 || w.FirstActivity != nil || w.LastActivity != nil || err != nil {
+		t.Error("Empty wallet does not match:", w, err)
+	}
+
+	if _, err := GetWallet("not a key"); err == nil {
+		t.Error("Invalid public key accepted")
+	}
+}
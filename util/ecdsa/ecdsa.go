@@ -6,9 +6,13 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"math/big"
 )
 
+// coordinateSize is the size of a P-256 coordinate, and of the r and s values of a signature, in bytes.
+const coordinateSize = 32
+
 // GenerateNewKey generates a new ECDSA private and public key using the P-256 curve.
 func GenerateNewKey() (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
@@ -35,34 +39,44 @@ func ParsePrivKey(key string) (*ecdsa.PrivateKey, error) {
 	return keyParsed, nil
 }
 
-// ExportPubKey exports the ECDSA public key as a Base64 encoded string.
+// ExportPubKey exports the ECDSA public key as a Base64 encoded string of the X and Y coordinates,
+// each padded to 32 bytes.
 func ExportPubKey(key *ecdsa.PublicKey) string {
-	return base64.StdEncoding.EncodeToString(append(key.X.Bytes(), key.Y.Bytes()...))
+	keyBytes := make([]byte, 2*coordinateSize)
+	key.X.FillBytes(keyBytes[:coordinateSize])
+	key.Y.FillBytes(keyBytes[coordinateSize:])
+	return base64.StdEncoding.EncodeToString(keyBytes)
 }
 
-// ParsePubKey parses a Base64 string to an ECDSA public key.
+// ParsePubKey parses a Base64 string to an ECDSA public key. The key must be 64 bytes (X and Y), encoded
+// as ExportPubKey does, and a point on the P-256 curve.
 func ParsePubKey(key string) (*ecdsa.PublicKey, error) {
 	keyBytes, err := base64.StdEncoding.DecodeString(key)
 	if err != nil {
 		return nil, err
 	}
 
-	x := big.Int{}
-	y := big.Int{}
-	sigLen := len(keyBytes)
-	x.SetBytes(keyBytes[:(sigLen / 2)])
-	y.SetBytes(keyBytes[(sigLen / 2):])
+	if len(keyBytes) != 2*coordinateSize {
+		return nil, errors.New("Public key must be 64 bytes")
+	}
 
-	keyParsed := ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
+	// Addresses are compared as strings, so every key has only one encoding
+	if base64.StdEncoding.EncodeToString(keyBytes) != key {
+		return nil, errors.New("Public key must be canonical Base64")
+	}
 
-	if err != nil {
-		return nil, err
+	x := new(big.Int).SetBytes(keyBytes[:coordinateSize])
+	y := new(big.Int).SetBytes(keyBytes[coordinateSize:])
+
+	if !elliptic.P256().IsOnCurve(x, y) {
+		return nil, errors.New("Public key must be a point on the P-256 curve")
 	}
 
-	return &keyParsed, nil
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
 }
 
-// Sign creates a signature using a SHA256 hash and ECDSA private key.
+// Sign creates a signature using a SHA256 hash and ECDSA private key: the Base64 encoded r and s values,
+// each padded to 32 bytes.
 func Sign(privKey *ecdsa.PrivateKey, hash []byte) (string, error) {
 	r, s, err := ecdsa.Sign(rand.Reader, privKey, hash[:])
 
@@ -70,8 +84,10 @@ func Sign(privKey *ecdsa.PrivateKey, hash []byte) (string, error) {
 		return "", err
 	}
 
-	signatureBytes := r.Bytes()
-	signatureBytes = append(signatureBytes, s.Bytes()...)
+	// r and s are padded, so the signature can be split in half
+	signatureBytes := make([]byte, 2*coordinateSize)
+	r.FillBytes(signatureBytes[:coordinateSize])
+	s.FillBytes(signatureBytes[coordinateSize:])
 
 	signature := base64.StdEncoding.EncodeToString(signatureBytes)
 
@@ -85,6 +101,7 @@ func Verify(pubKey *ecdsa.PublicKey, hash []byte, signature string) (bool, error
 		return false, err
 	}
 
+	// Signatures made before r and s were padded are split in half too
 	r := big.Int{}
 	s := big.Int{}
 	sigLen := len(signatureBlocks)
@@ -3,6 +3,7 @@ package ecdsa
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/base64"
 	"testing"
 )
 
@@ -69,3 +70,57 @@ func TestVerify(t *testing.T) {
 		t.Error("Verify failed:", result, signature, err)
 	}
 }
+
+func TestParsePubKeyInvalid(t *testing.T) {
+	privateKey, _ := GenerateNewKey()
+	keyBytes, _ := base64.StdEncoding.DecodeString(ExportPubKey(&privateKey.PublicKey))
+
+	offCurve := append([]byte(nil), keyBytes...)
+	offCurve[63] ^= 1
+
+	keys := map[string][]byte{
+		"empty":     {},
+		"short":     keyBytes[:63],
+		"long":      append(keyBytes, 0),
+		"off curve": offCurve,
+	}
+
+	for name, key := range keys {
+		if parsed, err := ParsePubKey(base64.StdEncoding.EncodeToString(key)); parsed != nil || err == nil {
+			t.Error("Invalid public key was parsed:", name, parsed)
+		}
+	}
+}
+
+func TestExportPubKeyPadding(t *testing.T) {
+	for i := 0; i < 512; i++ {
+		privateKey, _ := GenerateNewKey()
+
+		if pubKey := ExportPubKey(&privateKey.PublicKey); len(pubKey) != 88 {
+			t.Fatal("Public key is not padded:", pubKey)
+		}
+
+		hash := sha256.Sum256([]byte("Test data"))
+		if signature, _ := Sign(privateKey, hash[:]); len(signature) != 88 {
+			t.Fatal("Signature is not padded:", signature)
+		}
+	}
+}
+
+func FuzzParsePubKey(f *testing.F) {
+	privateKey, _ := GenerateNewKey()
+	f.Add(ExportPubKey(&privateKey.PublicKey))
+	f.Add("")
+	f.Add("AAAA")
+
+	f.Fuzz(func(t *testing.T, key string) {
+		parsed, err := ParsePubKey(key)
+		if err != nil {
+			return
+		}
+
+		if !parsed.Curve.IsOnCurve(parsed.X, parsed.Y) || ExportPubKey(parsed) != key {
+			t.Error("Parsed public key is not valid:", key)
+		}
+	})
+}